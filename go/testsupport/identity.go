@@ -0,0 +1,58 @@
+package testsupport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/msp"
+)
+
+// DefaultMSPID is the MSP of the identities set with Stub.SetCreator
+const DefaultMSPID = "Org1MSP"
+
+// attributesOID is the certificate extension in which Fabric CA stores the
+// attributes of an identity (see the cid library)
+var attributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// NewSerializedIdentity creates a self-signed X.509 certificate for the
+// given common name and attributes (may be nil), wrapped the way the peer
+// passes the creator to the chaincode. The ID returned by cid.GetID only
+// depends on the name, so the same name always stands for the same user.
+func NewSerializedIdentity(mspID string, name string, attrs map[string]string) []byte {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"viridian"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if attrs != nil {
+		value, err := json.Marshal(map[string]interface{}{"attrs": attrs})
+		if err != nil {
+			panic(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attributesOID, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		panic(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: certPEM})
+	if err != nil {
+		panic(err)
+	}
+	return creator
+}
@@ -0,0 +1,96 @@
+package testsupport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// CouchIndex is the definition of an index in META-INF/statedb/couchdb/indexes
+type CouchIndex struct {
+	Index struct {
+		Fields []string `json:"fields"`
+	} `json:"index"`
+	Ddoc string `json:"ddoc"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ShippedIndexes reads the definitions of the indexes in the directory
+func ShippedIndexes(dir string) ([]CouchIndex, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []CouchIndex
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var index CouchIndex
+		err = json.Unmarshal(content, &index)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// CheckIndex returns an error unless the query names an index in the
+// directory which CouchDB can use for it, i.e. the selector has a condition
+// on every field of the index and the query only sorts by fields of the index
+func CheckIndex(dir string, q *Query) error {
+	var ddoc, name string
+	switch useIndex := q.UseIndex.(type) {
+	case string:
+		ddoc = useIndex
+	case []interface{}:
+		if len(useIndex) == 2 {
+			ddoc, _ = useIndex[0].(string)
+			name, _ = useIndex[1].(string)
+		}
+	}
+	if len(ddoc) == 0 {
+		return fmt.Errorf("query does not specify use_index: %v", q.Selector)
+	}
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+
+	indexes, err := ShippedIndexes(dir)
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if index.Ddoc != ddoc || len(name) > 0 && index.Name != name {
+			continue
+		}
+		for _, field := range index.Index.Fields {
+			if _, ok := q.Selector[field]; !ok {
+				return fmt.Errorf("index %s cannot be used without a condition on %s: %v", index.Name, field, q.Selector)
+			}
+		}
+		order, err := q.sortFields()
+		if err != nil {
+			return err
+		}
+		for _, f := range order {
+			if !contains(index.Index.Fields, f.field) {
+				return fmt.Errorf("index %s cannot be used to sort by %s", index.Name, f.field)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("index %v is not defined in %s", q.UseIndex, dir)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
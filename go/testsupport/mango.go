@@ -0,0 +1,413 @@
+package testsupport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// In-memory emulation of CouchDB rich queries
+//
+// The emulation evaluates the subset of Mango queries that the chaincode
+// uses: the selector operators $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin,
+// $exists, $regex, $elemMatch, $allMatch, $size, $and, $or, $nor and $not,
+// as well as sort, limit, skip and bookmark pagination. Other operators make
+// the query fail, so a test notices when it relies on them. Values are
+// compared in CouchDB's collation order (null < false < true < numbers <
+// strings < arrays < objects), but strings are compared by code points and
+// not with ICU collation. As in CouchDB, a field condition other than
+// `$exists: false` never matches a document without the field, not even $ne
+// or $not.
+
+// Query is a CouchDB (Mango) query
+type Query struct {
+	Selector map[string]interface{} `json:"selector"`
+	UseIndex interface{}            `json:"use_index"` // design document or [design document, index name]
+	Sort     []interface{}          `json:"sort"`      // field names or {"field": "asc"/"desc"}
+	Limit    int                    `json:"limit"`
+	Skip     int                    `json:"skip"`
+	Fields   []string               `json:"fields"` // ignored, the chaincode always gets whole documents
+}
+
+// sortField is one field of the sort order of a query
+type sortField struct {
+	field      string
+	descending bool
+}
+
+// queryResults returns the query and the JSON documents matching its
+// selector, sorted by the sort order of the query and then by key
+func (s *Stub) queryResults(query string) (*Query, []*queryresult.KV, error) {
+	s.Queries = append(s.Queries, query)
+	if s.LevelDB {
+		return nil, nil, errors.New("ExecuteQuery not supported for leveldb")
+	}
+	q := new(Query)
+	err := json.Unmarshal([]byte(query), q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if q.Selector == nil {
+		return nil, nil, errors.New("query has no selector: " + query)
+	}
+	order, err := q.sortFields()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(s.IndexDir) > 0 {
+		err = CheckIndex(s.IndexDir, q)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var kvs []*queryresult.KV
+	var docs []map[string]interface{}
+	for _, key := range s.sortedKeys() {
+		var doc map[string]interface{}
+		if json.Unmarshal(s.State[key], &doc) != nil {
+			continue // not a JSON document, e.g. a composite key index entry
+		}
+		matches, err := Matches(doc, q.Selector)
+		if err != nil {
+			return nil, nil, err
+		}
+		if matches {
+			kvs = append(kvs, &queryresult.KV{Key: key, Value: s.State[key]})
+			docs = append(docs, doc)
+		}
+	}
+	if len(order) > 0 {
+		indexes := make([]int, len(kvs))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		sort.SliceStable(indexes, func(i, j int) bool {
+			for _, f := range order {
+				a, _ := lookup(docs[indexes[i]], f.field)
+				b, _ := lookup(docs[indexes[j]], f.field)
+				c := collate(a, b)
+				if c != 0 {
+					return c < 0 != f.descending
+				}
+			}
+			return false
+		})
+		sorted := make([]*queryresult.KV, len(kvs))
+		for i, index := range indexes {
+			sorted[i] = kvs[index]
+		}
+		kvs = sorted
+	}
+	return q, kvs, nil
+}
+
+func (q *Query) sortFields() ([]sortField, error) {
+	var order []sortField
+	for _, item := range q.Sort {
+		switch item := item.(type) {
+		case string:
+			order = append(order, sortField{item, false})
+		case map[string]interface{}:
+			if len(item) != 1 {
+				return nil, fmt.Errorf("invalid sort field: %v", item)
+			}
+			for field, direction := range item {
+				if direction != "asc" && direction != "desc" {
+					return nil, fmt.Errorf("invalid sort direction: %v", item)
+				}
+				order = append(order, sortField{field, direction == "desc"})
+			}
+		default:
+			return nil, fmt.Errorf("invalid sort field: %v", item)
+		}
+	}
+	return order, nil
+}
+
+// Matches reports whether the JSON document matches the Mango selector
+func Matches(doc map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for key, condition := range selector {
+		var matches bool
+		var err error
+		switch key {
+		case "$and", "$or", "$nor":
+			matches, err = matchesCombination(doc, key, condition)
+		case "$not":
+			subSelector, ok := condition.(map[string]interface{})
+			if !ok {
+				return false, errors.New("$not must be a selector")
+			}
+			matches, err = Matches(doc, subSelector)
+			matches = !matches
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, errors.New("unsupported operator " + key)
+			}
+			value, exists := lookup(doc, key)
+			matches, err = matchesCondition(value, exists, condition)
+		}
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchesCombination evaluates $and, $or or $nor on a list of selectors
+func matchesCombination(doc map[string]interface{}, operator string, operand interface{}) (bool, error) {
+	selectors, ok := operand.([]interface{})
+	if !ok {
+		return false, errors.New(operator + " must be a list of selectors")
+	}
+	count := 0
+	for _, s := range selectors {
+		subSelector, ok := s.(map[string]interface{})
+		if !ok {
+			return false, errors.New(operator + " must be a list of selectors")
+		}
+		matches, err := Matches(doc, subSelector)
+		if err != nil {
+			return false, err
+		}
+		if matches {
+			count++
+		}
+	}
+	switch operator {
+	case "$and":
+		return count == len(selectors), nil
+	case "$or":
+		return count > 0, nil
+	default:
+		return count == 0, nil
+	}
+}
+
+// matchesCondition reports whether the value of a field matches the
+// condition, which is either a value (equality) or an object of operators
+func matchesCondition(value interface{}, exists bool, condition interface{}) (bool, error) {
+	operators, ok := condition.(map[string]interface{})
+	if !ok || !isOperatorObject(operators) {
+		return exists && collate(value, condition) == 0, nil
+	}
+	for operator, operand := range operators {
+		matches, err := matchesOperator(value, exists, operator, operand)
+		if err != nil || !matches {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchesOperator(value interface{}, exists bool, operator string, operand interface{}) (bool, error) {
+	switch operator {
+	case "$eq":
+		return exists && collate(value, operand) == 0, nil
+	case "$ne":
+		return exists && collate(value, operand) != 0, nil
+	case "$gt":
+		return exists && collate(value, operand) > 0, nil
+	case "$gte":
+		return exists && collate(value, operand) >= 0, nil
+	case "$lt":
+		return exists && collate(value, operand) < 0, nil
+	case "$lte":
+		return exists && collate(value, operand) <= 0, nil
+	case "$in", "$nin":
+		list, ok := operand.([]interface{})
+		if !ok {
+			return false, errors.New(operator + " must be a list")
+		}
+		in := exists && isIn(value, list)
+		if operator == "$in" {
+			return in, nil
+		}
+		return exists && !in, nil
+	case "$exists":
+		shouldExist, ok := operand.(bool)
+		if !ok {
+			return false, errors.New("$exists must be true or false")
+		}
+		return exists == shouldExist, nil
+	case "$regex":
+		pattern, ok := operand.(string)
+		if !ok {
+			return false, errors.New("$regex must be a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		str, isString := value.(string)
+		return isString && re.MatchString(str), nil
+	case "$size":
+		size, ok := operand.(float64)
+		elements, isArray := value.([]interface{})
+		return ok && isArray && float64(len(elements)) == size, nil
+	case "$elemMatch", "$allMatch":
+		elements, isArray := value.([]interface{})
+		if !isArray {
+			return false, nil
+		}
+		count := 0
+		for _, element := range elements {
+			matches, err := matchesElement(element, operand)
+			if err != nil {
+				return false, err
+			}
+			if matches {
+				count++
+			}
+		}
+		if operator == "$elemMatch" {
+			return count > 0, nil
+		}
+		return len(elements) > 0 && count == len(elements), nil
+	case "$not":
+		matches, err := matchesCondition(value, exists, operand)
+		return exists && !matches, err
+	}
+	return false, errors.New("unsupported operator " + operator)
+}
+
+// matchesElement reports whether an array element matches the selector of
+// $elemMatch or $allMatch, which has fields (for elements that are objects)
+// or operators (for elements that are values)
+func matchesElement(element interface{}, selector interface{}) (bool, error) {
+	sub, ok := selector.(map[string]interface{})
+	if !ok {
+		return false, errors.New("$elemMatch and $allMatch must be a selector")
+	}
+	if isOperatorObject(sub) {
+		return matchesCondition(element, true, sub)
+	}
+	object, isObject := element.(map[string]interface{})
+	if !isObject {
+		return false, nil
+	}
+	return Matches(object, sub)
+}
+
+// isIn reports whether the value (or one of its elements, if it is an
+// array) is in the list
+func isIn(value interface{}, list []interface{}) bool {
+	candidates := []interface{}{value}
+	if elements, isArray := value.([]interface{}); isArray {
+		candidates = append(candidates, elements...)
+	}
+	for _, candidate := range candidates {
+		for _, item := range list {
+			if collate(candidate, item) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isOperatorObject(object map[string]interface{}) bool {
+	if len(object) == 0 {
+		return false
+	}
+	for key := range object {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the value of the (dotted) field in the document and
+// whether it exists
+func lookup(doc map[string]interface{}, field string) (interface{}, bool) {
+	var value interface{} = doc
+	for _, name := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// collationRank returns the position of the type of the JSON value in
+// CouchDB's collation order
+func collationRank(value interface{}) int {
+	switch value := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		if value {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// collate compares two JSON values in CouchDB's collation order and returns
+// -1, 0 or 1
+func collate(a interface{}, b interface{}) int {
+	rankA, rankB := collationRank(a), collationRank(b)
+	if rankA != rankB {
+		return compareInts(rankA, rankB)
+	}
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			c := collate(a[i], b[i])
+			if c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(a), len(b))
+	case map[string]interface{}:
+		if reflect.DeepEqual(a, b) {
+			return 0
+		}
+		jsonA, _ := json.Marshal(a)
+		jsonB, _ := json.Marshal(b)
+		if string(jsonA) < string(jsonB) {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a int, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
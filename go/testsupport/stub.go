@@ -0,0 +1,295 @@
+// Package testsupport provides a shim.MockStub wrapper for unit tests of the
+// chaincode. It adds what the plain MockStub lacks: settable creator
+// identities that can be read with the cid library, deterministic transaction
+// timestamps, the history of every key and an in-memory emulation of CouchDB
+// rich queries (see mango.go).
+package testsupport
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// FirstTxTimestamp is the timestamp of the first transaction of a Stub. Every
+// following transaction happens one second later, unless TxTimestamp is set.
+var FirstTxTimestamp = timestamp.Timestamp{Seconds: 1546300800} // 2019-01-01T00:00:00Z
+
+// Stub is a shim.MockStub that runs one chaincode
+type Stub struct {
+	*shim.MockStub
+	cc          shim.Chaincode
+	args        [][]byte
+	creator     []byte
+	txCount     int64
+	history     map[string][]*queryresult.KeyModification
+	TxTimestamp *timestamp.Timestamp // if set, the timestamp of all following transactions
+	LevelDB     bool                 // if true, rich queries fail like on LevelDB
+	IndexDir    string               // if set, rich queries must use one of the indexes defined in this directory
+	Queries     []string             // all rich queries executed
+	Reads       []string             // all keys read with GetState
+}
+
+// NewStub returns a stub running the chaincode
+func NewStub(cc shim.Chaincode) *Stub {
+	return &Stub{MockStub: shim.NewMockStub("testingStub", cc), cc: cc,
+		history: make(map[string][]*queryresult.KeyModification)}
+}
+
+// SetCreator makes all following transactions be submitted by the user with
+// the given (common) name
+func (s *Stub) SetCreator(name string) {
+	s.creator = NewSerializedIdentity(DefaultMSPID, name, nil)
+}
+
+// SetCreatorWithAttributes makes all following transactions be submitted by
+// the user with the given MSP ID, (common) name and attributes, which can be
+// read with cid.GetAttributeValue
+func (s *Stub) SetCreatorWithAttributes(mspID string, name string, attrs map[string]string) {
+	s.creator = NewSerializedIdentity(mspID, name, attrs)
+}
+
+// Init calls Init of the chaincode in a transaction with the given ID
+func (s *Stub) Init(txID string, args ...string) peer.Response {
+	s.startTransaction(txID, append([]string{"init"}, args...))
+	defer s.MockTransactionEnd(txID)
+	return s.cc.Init(s)
+}
+
+// Invoke calls Invoke of the chaincode in a transaction with the given ID.
+// The first argument is the function name.
+func (s *Stub) Invoke(txID string, args ...string) peer.Response {
+	s.startTransaction(txID, args)
+	defer s.MockTransactionEnd(txID)
+	return s.cc.Invoke(s)
+}
+
+func (s *Stub) startTransaction(txID string, args []string) {
+	s.args = make([][]byte, len(args))
+	for i, arg := range args {
+		s.args[i] = []byte(arg)
+	}
+	s.txCount++
+	s.MockTransactionStart(txID)
+}
+
+// PutFixture writes an asset directly to state, bypassing the chaincode
+func (s *Stub) PutFixture(key string, asset interface{}) {
+	jsonAsBytes, err := json.Marshal(asset)
+	if err != nil {
+		panic(err)
+	}
+	s.MockTransactionStart("fixture")
+	defer s.MockTransactionEnd("fixture")
+	err = s.PutState(key, jsonAsBytes)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetFixture reads an asset directly from state into asset and reports
+// whether it exists
+func (s *Stub) GetFixture(key string, asset interface{}) bool {
+	jsonAsBytes, ok := s.State[key]
+	if !ok {
+		return false
+	}
+	err := json.Unmarshal(jsonAsBytes, asset)
+	if err != nil {
+		panic(err)
+	}
+	return true
+}
+
+// GetArgs returns the arguments of the current transaction
+func (s *Stub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs returns the arguments of the current transaction as strings
+func (s *Stub) GetStringArgs() []string {
+	strargs := make([]string, 0, len(s.args))
+	for _, barg := range s.args {
+		strargs = append(strargs, string(barg))
+	}
+	return strargs
+}
+
+// GetFunctionAndParameters returns the first argument as the function name
+// and the rest of the arguments as parameters
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	allargs := s.GetStringArgs()
+	if len(allargs) == 0 {
+		return "", []string{}
+	}
+	return allargs[0], allargs[1:]
+}
+
+// GetCreator returns the identity set with SetCreator
+func (s *Stub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+// GetTxTimestamp returns TxTimestamp if set. Otherwise, the n-th transaction
+// happens n-1 seconds after FirstTxTimestamp, so the timestamps do not depend
+// on the time the tests run.
+func (s *Stub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	if s.TxTimestamp != nil {
+		return s.TxTimestamp, nil
+	}
+	return &timestamp.Timestamp{Seconds: FirstTxTimestamp.Seconds + s.txCount - 1, Nanos: FirstTxTimestamp.Nanos}, nil
+}
+
+// GetState reads the value of key from state and records the read
+func (s *Stub) GetState(key string) ([]byte, error) {
+	s.Reads = append(s.Reads, key)
+	return s.MockStub.GetState(key)
+}
+
+// PutState writes the value to state and records it in the history of key
+func (s *Stub) PutState(key string, value []byte) error {
+	s.recordHistory(key, value, false)
+	return s.MockStub.PutState(key, value)
+}
+
+// DelState deletes the key from state and records it in its history
+func (s *Stub) DelState(key string) error {
+	s.recordHistory(key, nil, true)
+	return s.MockStub.DelState(key)
+}
+
+// recordHistory appends a modification of key by the current transaction to
+// its history
+func (s *Stub) recordHistory(key string, value []byte, isDelete bool) {
+	txTimestamp, _ := s.GetTxTimestamp()
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{
+		TxId: s.TxID, Value: value, Timestamp: txTimestamp, IsDelete: isDelete})
+}
+
+// GetHistoryForKey returns all modifications of key, oldest first
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{append([]*queryresult.KeyModification{}, s.history[key]...)}, nil
+}
+
+// GetQueryResult executes the rich query (see mango.go). Like CouchDB, it
+// returns at most `limit` results after skipping `skip` results.
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	q, kvs, err := s.queryResults(query)
+	if err != nil {
+		return nil, err
+	}
+	if q.Skip > 0 {
+		if q.Skip > len(kvs) {
+			q.Skip = len(kvs)
+		}
+		kvs = kvs[q.Skip:]
+	}
+	if q.Limit > 0 && q.Limit < len(kvs) {
+		kvs = kvs[:q.Limit]
+	}
+	return &queryIterator{kvs}, nil
+}
+
+// GetQueryResultWithPagination executes the rich query and returns the page
+// of at most pageSize results following the bookmark. Like in Fabric, the
+// page size replaces `limit`.
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	_, kvs, err := s.queryResults(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(kvs, pageSize, bookmark)
+}
+
+// GetStateByRangeWithPagination returns the page of at most pageSize keys in
+// [startKey, endKey) following the bookmark
+func (s *Stub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	var kvs []*queryresult.KV
+	for _, key := range s.sortedKeys() {
+		if key >= startKey && key < endKey {
+			kvs = append(kvs, &queryresult.KV{Key: key, Value: s.State[key]})
+		}
+	}
+	return paginate(kvs, pageSize, bookmark)
+}
+
+func (s *Stub) sortedKeys() []string {
+	keys := make([]string, 0, len(s.State))
+	for key := range s.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// paginate returns the page of at most pageSize results following the
+// bookmark, which is the key of the last result of the previous page. If that
+// result is gone, the page starts at the next greater key.
+func paginate(kvs []*queryresult.KV, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	start := 0
+	if len(bookmark) > 0 {
+		start = len(kvs)
+		for i, kv := range kvs {
+			if kv.Key == bookmark {
+				start = i + 1
+				break
+			}
+			if kv.Key > bookmark && i < start {
+				start = i
+			}
+		}
+	}
+	end := start + int(pageSize)
+	if end > len(kvs) {
+		end = len(kvs)
+	}
+	page := &queryIterator{kvs[start:end]}
+	if len(page.kvs) > 0 {
+		bookmark = page.kvs[len(page.kvs)-1].Key
+	}
+	return page, &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(page.kvs)), Bookmark: bookmark}, nil
+}
+
+// queryIterator iterates over a precomputed list of query results
+type queryIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *queryIterator) HasNext() bool {
+	return len(it.kvs) > 0
+}
+
+func (it *queryIterator) Next() (*queryresult.KV, error) {
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+func (it *queryIterator) Close() error {
+	return nil
+}
+
+// historyIterator iterates over the recorded modifications of a key
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.modifications) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	modification := it.modifications[0]
+	it.modifications = it.modifications[1:]
+	return modification, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
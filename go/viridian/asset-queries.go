@@ -0,0 +1,309 @@
+package viridian
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Ad hoc queries
+//
+// queryAssets lets apps pass their own CouchDB selectors. As a selector can
+// make CouchDB scan the whole state database, only a restricted subset of
+// Mango is accepted: the fields and operators must be on the allowlist of the
+// docType, the nesting and number of conditions are limited, the query must
+// use one of the indexes shipped with the chaincode and the number of results
+// per call is capped (see Config.MaxQueryLimit).
+
+// queryAllowlist holds the fields and operators an ad hoc query may use
+type queryAllowlist struct {
+	fields    []string // fields of array elements are listed as "array.field" and can only be queried with $elemMatch
+	operators []string
+}
+
+// comparisonOperators are the operators allowed for all docTypes. Operators
+// that cannot use an index or are expensive to evaluate ($regex, $where-like
+// ones, $nor, $not) are not allowed.
+var comparisonOperators = []string{
+	"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in", "$nin", "$exists", "$elemMatch", "$and", "$or",
+}
+
+// queryAllowlists maps the docTypes that can be queried to their allowlist
+var queryAllowlists = map[string]queryAllowlist{
+	"product": {
+		fields: []string{"gtin", "producers", "producer", "containedProducts", "productCategories", "labels", "status", // producer: products stored with a single producer
+			"createdBy", "updatedBy", "supersedes", "supersededBy",
+			"locales", "locales.lang", "locales.name", "locales.categories", "locales.packagings"},
+		operators: comparisonOperators,
+	},
+	"producer": {
+		fields: []string{"labels", "status", "createdBy", "updatedBy", "supersedes", "supersededBy",
+			"locales", "locales.lang", "locales.name", "locales.address",
+			"name", "address", "url"}, // name, address, url: producers stored before they had locales
+		operators: comparisonOperators,
+	},
+	"label": {
+		fields: []string{"version", "status", "createdBy", "updatedBy", "supersedes", "supersededBy",
+			"locales", "locales.lang", "locales.name", "locales.categories"},
+		operators: comparisonOperators,
+	},
+	"review": {
+		fields:    []string{"target", "user", "requestedAt", "decision", "timestamp", "rejectReason"},
+		operators: comparisonOperators,
+	},
+	"information": {
+		fields:    []string{"title", "category", "target", "weight", "status", "createdBy", "updatedBy", "supersedes", "supersededBy"},
+		operators: comparisonOperators,
+	},
+	"comment": {
+		fields:    []string{"target", "lang", "weight", "status", "createdBy"},
+		operators: comparisonOperators,
+	},
+}
+
+const (
+	maxSelectorDepth      = 4  // nesting of $and, $or and $elemMatch
+	maxSelectorConditions = 20 // number of conditions in a selector
+	maxInOperands         = 50 // number of values in $in and $nin
+)
+
+// adHocQuery is the subset of a CouchDB query accepted by queryAssets
+type adHocQuery struct {
+	Selector map[string]interface{} `json:"selector"`
+	Limit    int                    `json:"limit"`
+	UseIndex []string               `json:"use_index"` // design document and name of the index
+	Sort     []map[string]string    `json:"sort,omitempty"`
+}
+
+// queryAssets executes an ad hoc query for assets of one docType and returns
+// one page of the results
+// Only available on state databases that support rich query (e.g. CouchDB)
+func queryAssets(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0            1                                                                                                    2
+	// DocType,    Query,                                                                                              Bookmark
+	// "product",  `{"selector": {"status": 2}, "limit": 20, "use_index": ["indexDocTypeDoc", "indexDocType"]}`,   "" for the first page
+	if len(args) < 2 || len(args) > 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 or 3.")
+	}
+
+	// === Arg 0: DocType ===
+	docType := args[0]
+	allowlist, ok := queryAllowlists[docType]
+	if !ok {
+		return shim.Error("Assets of type '" + docType + "' cannot be queried")
+	}
+
+	// === Arg 1: Query ===
+	var query adHocQuery
+	decoder := json.NewDecoder(bytes.NewReader([]byte(args[1])))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&query)
+	if err != nil {
+		return shim.Error("'query' must be a JSON object with 'selector', 'use_index' and optionally 'limit' and 'sort': " + err.Error())
+	}
+	err = checkAdHocQuery(stub, &query, docType, allowlist)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// === Arg 2: Bookmark ===
+	bookmark := optionalArg(args, 2)
+
+	// The limit becomes the page size, so the next page can be requested with the bookmark
+	pageSize := int32(query.Limit)
+	queryString, err := json.Marshal(struct {
+		Selector map[string]interface{} `json:"selector"`
+		UseIndex []string               `json:"use_index"`
+		Sort     []map[string]string    `json:"sort,omitempty"`
+	}{query.Selector, query.UseIndex, query.Sort})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	queryResults, err := getQueryResultForQueryStringWithPagination(stub, string(queryString), pageSize, bookmark)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// checkAdHocQuery validates the query against the allowlist, caps its limit
+// and adds the docType condition
+func checkAdHocQuery(stub shim.ChaincodeStubInterface, query *adHocQuery, docType string, allowlist queryAllowlist) error {
+	if len(query.Selector) == 0 {
+		return errors.New("'selector' must not be empty")
+	}
+	if _, ok := query.Selector["docType"]; ok {
+		return errors.New("'selector' must not contain 'docType', it is set by the chaincode")
+	}
+	conditions := 0
+	err := checkSelector(query.Selector, "", allowlist, 0, &conditions)
+	if err != nil {
+		return err
+	}
+	query.Selector["docType"] = docType
+
+	if len(query.UseIndex) != 2 || shippedIndex(query.UseIndex[0], query.UseIndex[1]) == nil {
+		return errors.New("'use_index' must be [design document, index name] of an index in META-INF/statedb/couchdb/indexes")
+	}
+	index := shippedIndex(query.UseIndex[0], query.UseIndex[1])
+	// CouchDB can only use an index if the selector has a condition on each of its fields
+	for _, field := range index.fields {
+		if _, ok := query.Selector[field]; !ok {
+			return fmt.Errorf("'use_index' cannot be used without a condition on '%s'", field)
+		}
+	}
+	// and can only sort by fields of the index, otherwise the query fails or sorts all results in memory
+	for _, sortField := range query.Sort {
+		for field, direction := range sortField {
+			if !contains(index.fields, field) || (direction != "asc" && direction != "desc") {
+				return fmt.Errorf("Cannot sort by '%s' '%s', only by a field of the index in 'use_index' ('asc' or 'desc')", field, direction)
+			}
+		}
+	}
+
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	if query.Limit < 0 {
+		return errors.New("'limit' must not be negative")
+	}
+	if query.Limit == 0 || query.Limit > config.MaxQueryLimit {
+		query.Limit = config.MaxQueryLimit
+	}
+	return nil
+}
+
+// checkSelector checks that the selector only uses allowed fields and
+// operators. prefix is the path of the array whose elements the selector
+// matches (within $elemMatch), e.g. "locales.".
+func checkSelector(sel map[string]interface{}, prefix string, allowlist queryAllowlist, depth int, conditions *int) error {
+	if depth > maxSelectorDepth {
+		return fmt.Errorf("Selector must not be nested more than %d levels deep", maxSelectorDepth)
+	}
+	for key, value := range sel {
+		*conditions++
+		if *conditions > maxSelectorConditions {
+			return fmt.Errorf("Selector must not have more than %d conditions", maxSelectorConditions)
+		}
+		if strings.HasPrefix(key, "$") {
+			// combination operator, e.g. {"$or": [{...}, {...}]}
+			if !contains(allowlist.operators, key) || (key != "$and" && key != "$or") {
+				return fmt.Errorf("Operator '%s' is not allowed here", key)
+			}
+			selectors, ok := value.([]interface{})
+			if !ok || len(selectors) == 0 {
+				return fmt.Errorf("'%s' must be a non-empty list of selectors", key)
+			}
+			for _, s := range selectors {
+				subSelector, ok := s.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("'%s' must be a non-empty list of selectors", key)
+				}
+				err := checkSelector(subSelector, prefix, allowlist, depth+1, conditions)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		field := prefix + key
+		if !contains(allowlist.fields, field) || strings.Contains(key, ".") {
+			return fmt.Errorf("Field '%s' cannot be queried", field)
+		}
+		err := checkCondition(field, value, allowlist, depth, conditions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCondition checks the condition on field, which is either a value
+// (equality) or an object of operators, e.g. {"$gt": 1, "$lt": 5}
+func checkCondition(field string, value interface{}, allowlist queryAllowlist, depth int, conditions *int) error {
+	operators, ok := value.(map[string]interface{})
+	if !ok {
+		return checkOperand(field, "$eq", value)
+	}
+	if len(operators) == 0 {
+		return fmt.Errorf("Condition on '%s' must not be empty", field)
+	}
+	for operator, operand := range operators {
+		if !contains(allowlist.operators, operator) || operator == "$and" || operator == "$or" {
+			return fmt.Errorf("Operator '%s' is not allowed on field '%s'", operator, field)
+		}
+		if operator != "$elemMatch" {
+			err := checkOperand(field, operator, operand)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		element, ok := operand.(map[string]interface{})
+		if !ok || len(element) == 0 {
+			return fmt.Errorf("'$elemMatch' on '%s' must be a non-empty selector", field)
+		}
+		var err error
+		if isOperatorObject(element) {
+			// elements are values, e.g. {"labels": {"$elemMatch": {"$eq": "label-..."}}}
+			err = checkCondition(field, element, allowlist, depth+1, conditions)
+		} else {
+			// elements are objects, e.g. {"locales": {"$elemMatch": {"lang": "de"}}}
+			err = checkSelector(element, field+".", allowlist, depth+1, conditions)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOperand checks the operand of a comparison operator
+func checkOperand(field string, operator string, operand interface{}) error {
+	switch operator {
+	case "$in", "$nin":
+		values, ok := operand.([]interface{})
+		if !ok || len(values) > maxInOperands {
+			return fmt.Errorf("'%s' on '%s' must be a list of at most %d values", operator, field, maxInOperands)
+		}
+		for _, value := range values {
+			if !isScalar(value) {
+				return fmt.Errorf("'%s' on '%s' must only contain strings, numbers, booleans or null", operator, field)
+			}
+		}
+	case "$exists":
+		if _, ok := operand.(bool); !ok {
+			return fmt.Errorf("'$exists' on '%s' must be true or false", field)
+		}
+	default:
+		if !isScalar(operand) {
+			return fmt.Errorf("'%s' on '%s' must be a string, number, boolean or null", operator, field)
+		}
+	}
+	return nil
+}
+
+// isOperatorObject reports whether all keys of the object are operators
+func isOperatorObject(object map[string]interface{}) bool {
+	for key := range object {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool, nil:
+		return true
+	}
+	return false
+}
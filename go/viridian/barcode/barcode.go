@@ -0,0 +1,64 @@
+// Package barcode validates and normalizes GS1 barcodes (GTINs) as printed
+// on products: GTIN-8 (EAN-8), GTIN-12 (UPC-A), GTIN-13 (EAN-13) and GTIN-14.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GTINLength is the length of the canonical form of all GTINs
+const GTINLength = 14
+
+// Normalize validates a GTIN-8, GTIN-12, GTIN-13 or GTIN-14 and returns its
+// canonical GTIN-14 form, i.e. padded with leading zeros to 14 digits. The
+// zero-padded forms of the same barcode, e.g. "7612100055557" and
+// "07612100055557", have the same canonical form.
+func Normalize(gtin string) (string, error) {
+	err := Validate(gtin)
+	if err != nil {
+		return "", err
+	}
+	return strings.Repeat("0", GTINLength-len(gtin)) + gtin, nil
+}
+
+// Validate checks that gtin consists of 8, 12, 13 or 14 digits, the last of
+// which is the correct GS1 check digit
+func Validate(gtin string) error {
+	switch len(gtin) {
+	case 8, 12, 13, 14:
+	default:
+		return fmt.Errorf("GTIN %q must have 8 (GTIN-8), 12 (UPC-A), 13 (EAN-13) or 14 (GTIN-14) digits, not %d", gtin, len(gtin))
+	}
+	for _, digit := range gtin {
+		if digit < '0' || digit > '9' {
+			return fmt.Errorf("GTIN %q must only contain digits", gtin)
+		}
+	}
+	checkDigit, _ := CheckDigit(gtin[:len(gtin)-1])
+	if int(gtin[len(gtin)-1]-'0') != checkDigit {
+		return fmt.Errorf("GTIN %q has a wrong check digit, expected %d", gtin, checkDigit)
+	}
+	return nil
+}
+
+// CheckDigit calculates the GS1 mod-10 check digit for the digits of a GTIN
+// without check digit. Starting from the rightmost digit, the digits are
+// weighted alternately with 3 and 1. The check digit complements the weighted
+// sum to the next multiple of 10.
+func CheckDigit(digits string) (int, error) {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		digit := digits[len(digits)-1-i]
+		if digit < '0' || digit > '9' {
+			return 0, errors.New("must only contain digits")
+		}
+		weight := 1
+		if i%2 == 0 {
+			weight = 3
+		}
+		sum += weight * int(digit-'0')
+	}
+	return (10 - sum%10) % 10, nil
+}
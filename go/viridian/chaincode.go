@@ -16,8 +16,13 @@ import (
 //   The specialized methods belong to other chaincodes like
 //   ProductChaincode, ProducerChaincode, etc.
 type Chaincode struct {
-	Product  *ProductChaincode
-	Producer *ProducerChaincode
+	Product     *ProductChaincode
+	Producer    *ProducerChaincode
+	Label       *LabelChaincode
+	Review      *ReviewChaincode
+	User        *UserChaincode
+	Information *InformationChaincode
+	Comment     *CommentChaincode
 }
 
 // Init initializes the chaincode
@@ -25,6 +30,22 @@ type Chaincode struct {
 func (c *Chaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
 	c.Product = new(ProductChaincode)
 	c.Producer = new(ProducerChaincode)
+	c.Label = new(LabelChaincode)
+	c.Review = new(ReviewChaincode)
+	c.User = new(UserChaincode)
+	c.Information = new(InformationChaincode)
+	c.Comment = new(CommentChaincode)
+
+	// Optional argument: chaincode configuration as JSON
+	_, args := stub.GetFunctionAndParameters()
+	err := initConfig(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = migrateProducts(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 	return shim.Success(nil)
 }
 
@@ -37,29 +58,111 @@ func (c *Chaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 	// Handle the product functions
 	if function == "addProduct" { // create a new product
 		return c.Product.AddProduct(stub, args)
-		// } else if function == "delete" { // delete a product
-		// 	return c.delete(stub, args)
-		// } else if function == "readProduct" { //read a product
-		// 	return c.readProduct(stub, args)
+	} else if function == "editProduct" { // create a new version of a product
+		return c.Product.EditProduct(stub, args)
+	} else if function == "deleteProduct" { // request the deletion of a product
+		return c.Product.DeleteProduct(stub, args)
+	} else if function == "readProduct" { // read a product
+		return c.Product.ReadProduct(stub, args)
 	} else if function == "queryProductsByGTIN" { // find product for GTIN X using rich query
 		return c.Product.QueryProductsByGTIN(stub, args)
-		// } else if function == "queryProducts" { // find products based on an ad hoc rich query
-		// 	return c.queryProducts(stub, args)
-		// } else if function == "getHistoryForProduct" { // get history of values for a product
-		// 	return c.getHistoryForProduct(stub, args)
-		// } else if function == "getMarblesByRange" { //get marbles based on range query
-		// 	return c.getMarblesByRange(stub, args)
-		// } else if function == "getMarblesByRangeWithPagination" {
-		// 	return c.getMarblesByRangeWithPagination(stub, args)
-		// } else if function == "queryMarblesWithPagination" {
-		// 	return c.queryMarblesWithPagination(stub, args)
+	} else if function == "getHistoryForProduct" { // get history of values for a product
+		return c.Product.GetHistoryForProduct(stub, args)
+	} else if function == "queryProductsByGTINWithPagination" {
+		return c.Product.QueryProductsByGTINWithPagination(stub, args)
+	} else if function == "getProductsByRangeWithPagination" { // get products based on range query
+		return c.Product.GetProductsByRangeWithPagination(stub, args)
 	} else if function == "queryProductsByName" {
 		return c.Product.QueryProductsByName(stub, args)
+	} else if function == "queryProductsByNameWithPagination" {
+		return c.Product.QueryProductsByNameWithPagination(stub, args)
+	} else if function == "getProductComposition" { // get the tree of contained products
+		return c.Product.GetProductComposition(stub, args)
+	} else if function == "queryProductsByProducer" {
+		return c.Product.QueryProductsByProducer(stub, args)
+	} else if function == "queryProductsByProducerWithPagination" {
+		return c.Product.QueryProductsByProducerWithPagination(stub, args)
+	} else if function == "queryProductsByStatus" {
+		return c.Product.QueryProductsByStatus(stub, args)
+	} else if function == "queryProductsByStatusWithPagination" {
+		return c.Product.QueryProductsByStatusWithPagination(stub, args)
+	} else if function == "queryProductsByLabel" {
+		return c.Product.QueryProductsByLabel(stub, args)
+	} else if function == "queryProductsByLabelWithPagination" {
+		return c.Product.QueryProductsByLabelWithPagination(stub, args)
+	} else if function == "queryProductsByCategory" {
+		return c.Product.QueryProductsByCategory(stub, args)
+	} else if function == "queryProductsByCategoryWithPagination" {
+		return c.Product.QueryProductsByCategoryWithPagination(stub, args)
 	}
 
 	// Handle the producer functions
 	if function == "initProducer" {
 		return c.Producer.InitProducer(stub, args)
+	} else if function == "readProducer" { // read a producer
+		return c.Producer.ReadProducer(stub, args)
+	} else if function == "getHistoryForProducer" { // get history of values for a producer
+		return c.Producer.GetHistoryForProducer(stub, args)
+	} else if function == "getProducersByRangeWithPagination" { // get producers based on range query
+		return c.Producer.GetProducersByRangeWithPagination(stub, args)
+	} else if function == "queryProducersByName" { // find producers by name using rich query
+		return c.Producer.QueryProducersByName(stub, args)
+	} else if function == "queryProducersByNameWithPagination" {
+		return c.Producer.QueryProducersByNameWithPagination(stub, args)
+	}
+
+	// Handle the label functions
+	if function == "addLabel" { // create a new label
+		return c.Label.AddLabel(stub, args)
+	} else if function == "editLabel" { // create a new version of a label
+		return c.Label.EditLabel(stub, args)
+	} else if function == "readLabel" { // read a label
+		return c.Label.ReadLabel(stub, args)
+	} else if function == "getHistoryForLabel" { // get history of values for a label
+		return c.Label.GetHistoryForLabel(stub, args)
+	} else if function == "queryLabelsByName" { // find labels by name using rich query
+		return c.Label.QueryLabelsByName(stub, args)
+	} else if function == "queryLabelsByNameWithPagination" {
+		return c.Label.QueryLabelsByNameWithPagination(stub, args)
+	} else if function == "getLabelsByRangeWithPagination" { // get labels based on range query
+		return c.Label.GetLabelsByRangeWithPagination(stub, args)
+	}
+
+	// Handle the review functions
+	if function == "submitReview" {
+		return c.Review.SubmitReview(stub, args)
+	} else if function == "declareConflict" {
+		return c.Review.DeclareConflict(stub, args)
+	} else if function == "withdrawConflict" {
+		return c.Review.WithdrawConflict(stub, args)
+	} else if function == "queryReviewsByUser" { // e.g. the review queue of a user
+		return c.Review.QueryReviewsByUser(stub, args)
+	} else if function == "queryReviewsByUserWithPagination" {
+		return c.Review.QueryReviewsByUserWithPagination(stub, args)
+	}
+
+	// Handle the user functions
+	if function == "registerUser" {
+		return c.User.RegisterUser(stub, args)
+	}
+
+	// Handle the information functions
+	if function == "queryInformationByTarget" {
+		return c.Information.QueryInformationByTarget(stub, args)
+	} else if function == "queryInformationByTargetWithPagination" {
+		return c.Information.QueryInformationByTargetWithPagination(stub, args)
+	}
+
+	// Handle the comment functions
+	if function == "queryCommentsByTarget" {
+		return c.Comment.QueryCommentsByTarget(stub, args)
+	} else if function == "queryCommentsByTargetWithPagination" {
+		return c.Comment.QueryCommentsByTargetWithPagination(stub, args)
+	}
+
+	// Handle the functions for all assets
+	if function == "queryAssets" { // find assets based on an ad hoc rich query
+		return queryAssets(stub, args)
 	}
 
 	fmt.Println("invoke did not find func: " + function) //error
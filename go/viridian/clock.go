@@ -0,0 +1,21 @@
+package viridian
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// txTime returns the timestamp of the transaction proposal, set by the
+// submitting client. Unlike the local clock (time.Now()), it is the same on
+// all endorsing peers, so the assets written by the transaction are
+// byte-identical and the endorsements match. All timestamps stored in assets
+// must be taken from here.
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New("Failed to get transaction timestamp: " + err.Error())
+	}
+	return time.Unix(txTimestamp.GetSeconds(), int64(txTimestamp.GetNanos())).UTC(), nil
+}
@@ -0,0 +1,62 @@
+package viridian
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+/**
+  Comments can be added by users either to a scorable asset like
+  Product/Label/Producer or to an Information. They are not reviewed before
+  going online, but can be flagged by other users, which initiates a review
+  deciding about the deletion of the comment.
+**/
+
+// CommentChaincode is the chaincode associated with comments
+type CommentChaincode struct {
+}
+
+// Comment is the asset representing a comment on a scorable asset or an information
+type Comment struct {
+	ReviewableAsset
+	DocType string `json:"docType"` // docType is used to distinguish the various types of objects in state database
+	Target  string `json:"target"`  // key of the commented scorable asset or information
+	Lang    string `json:"lang"`    // regex=/^[a-z]{2}$/ // ISO language code according to https://en.wikipedia.org/wiki/ISO_639-1
+	Title   string `json:"title"`   // optional
+	Text    string `json:"text"`
+	Weight  int32  `json:"weight"` // sum of votes
+}
+
+// queryStringForTarget returns the rich query for the comments on an asset
+func (c *CommentChaincode) queryStringForTarget(target string) (string, error) {
+	return newSelector("comment").eq("target", target).queryStringWithIndex(indexTargetDoc, indexTarget)
+}
+
+// QueryCommentsByTarget queries for the comments on the product, producer,
+// label or information with the given key
+func (c *CommentChaincode) QueryCommentsByTarget(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//                      0
+	// "product-1fcc2c43-12a1-4451-ac56-dd73099b3f34"
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	queryString, err := c.queryStringForTarget(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryCommentsByTargetWithPagination is the paginated variant of QueryCommentsByTarget
+func (c *CommentChaincode) QueryCommentsByTargetWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//          0            1     2
+	// "product-1fcc...",  "20", bookmark ("" for the first page)
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	queryString, err := c.queryStringForTarget(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
@@ -0,0 +1,219 @@
+package viridian
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Composition of products
+//
+// A product can contain other products (`containedProducts`), e.g. a gift box
+// contains a jar, which contains ingredients. No product may contain itself,
+// neither directly nor indirectly, nor through another version of itself
+// (the versions it supersedes), and contained products may only be nested
+// Config.MaxCompositionDepth levels deep. Otherwise, e.g. rolling up the
+// scores of the contained products would never end.
+//
+// A product can be contained in several products of a composition, so the
+// tree can be exponentially larger than the number of products in it. Writes
+// therefore check the composition with a depth-first search that reads every
+// product once (see compositionCheck), and only getProductComposition expands
+// the tree, up to maxCompositionNodes nodes.
+
+// maxCompositionNodes is the number of nodes getProductComposition expands
+// at most
+const maxCompositionNodes = 1000
+
+// CompositionNode is one product in the expanded composition tree of a product
+type CompositionNode struct {
+	Key               string             `json:"key"`
+	Status            Status             `json:"status"`            // 0 if the product could not be read
+	Problem           string             `json:"problem,omitempty"` // NOT_FOUND, WRONG_DOCTYPE, CYCLE or TOO_DEEP, contained products are not expanded then
+	ContainedProducts []*CompositionNode `json:"containedProducts"`
+}
+
+// GetProductComposition returns the fully expanded tree of the products
+// contained in a product, with the status of every node
+func (c *ProductChaincode) GetProductComposition(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//  0
+	// Product key
+	// "product-8a259c61-6825-..."
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+
+	key := args[0]
+	product, err := c.getProduct(stub, key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	nodes := 1
+	root, err := c.expandComposition(stub, key, product, nil, 0, config.MaxCompositionDepth, &nodes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	jsonAsBytes, err := json.Marshal(root)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonAsBytes)
+}
+
+// checkComposition makes sure that the product to be stored under key does
+// not contain itself and that its contained products are not nested too deep
+func (c *ProductChaincode) checkComposition(stub shim.ChaincodeStubInterface, key string, product *Product) error {
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	check := &compositionCheck{c, stub, config.MaxCompositionDepth, nil, map[string]int{}, map[string]string{}}
+	_, err = check.visit(key, product, []string{key})
+	return err
+}
+
+// compositionCheck is a depth-first search through the contained products.
+// A product that was checked completely is not read again when it is reached
+// on another path: the keys of the root are on the path during the whole
+// search, so a cycle through the root would have been found the first time,
+// and its nesting depth is known.
+type compositionCheck struct {
+	c        *ProductChaincode
+	stub     shim.ChaincodeStubInterface
+	maxDepth int
+	onPath   []string          // keys (of all versions) of the products on the path
+	heights  map[string]int    // levels nested below each completely checked product
+	deepest  map[string]string // contained product on the longest chain below each checked product
+}
+
+// visit checks the products contained in the product stored under key, which
+// is reached through path (from the root down to key), and returns the number
+// of levels nested below it
+func (cc *compositionCheck) visit(key string, product *Product, path []string) (int, error) {
+	versions, err := cc.c.productVersions(cc.stub, key, product)
+	if err != nil {
+		return 0, err
+	}
+	onPath := len(cc.onPath)
+	cc.onPath = append(cc.onPath, versions...)
+	defer func() { cc.onPath = cc.onPath[:onPath] }()
+
+	height := 0
+	for _, containedKey := range product.ContainedProducts {
+		childPath := append(append([]string{}, path...), containedKey)
+		if contains(cc.onPath, containedKey) {
+			return 0, fmt.Errorf("Contained products must not form a cycle: %s", strings.Join(childPath, " -> "))
+		}
+		h, checked := cc.heights[containedKey]
+		if !checked {
+			jsonAsBytes, err := cc.stub.GetState(containedKey)
+			if err != nil {
+				return 0, fmt.Errorf("Failed to get %s: %s", containedKey, err.Error())
+			}
+			contained := new(Product)
+			if jsonAsBytes == nil || json.Unmarshal(jsonAsBytes, contained) != nil || contained.DocType != "product" {
+				continue // reported by checkReferences
+			}
+			if len(path) > cc.maxDepth {
+				return 0, cc.tooDeep(childPath)
+			}
+			h, err = cc.visit(containedKey, contained, childPath)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if len(path)+h > cc.maxDepth {
+			return 0, cc.tooDeep(childPath)
+		}
+		if h+1 > height {
+			height = h + 1
+			cc.deepest[key] = containedKey
+		}
+	}
+	cc.heights[key] = height
+	return height, nil
+}
+
+// tooDeep returns the error for a composition nested too deep, continuing
+// path along the longest chain of checked products down to the first product
+// nested too deep
+func (cc *compositionCheck) tooDeep(path []string) error {
+	for len(path) <= cc.maxDepth+1 {
+		next, ok := cc.deepest[path[len(path)-1]]
+		if !ok {
+			break
+		}
+		path = append(path, next)
+	}
+	return fmt.Errorf("Contained products must not be nested more than %d levels deep: %s",
+		cc.maxDepth, strings.Join(path, " -> "))
+}
+
+// expandComposition builds the composition tree of the product stored under
+// key at the given depth. ancestors holds the keys (of all versions) of the
+// products on the path from the root down to this product. nodes counts the
+// nodes of the tree, which must not exceed maxCompositionNodes.
+func (c *ProductChaincode) expandComposition(stub shim.ChaincodeStubInterface, key string, product *Product,
+	ancestors []string, depth int, maxDepth int, nodes *int) (*CompositionNode, error) {
+	node := &CompositionNode{key, product.Status, "", []*CompositionNode{}}
+
+	versions, err := c.productVersions(stub, key, product)
+	if err != nil {
+		return nil, err
+	}
+	path := append(append([]string{}, ancestors...), versions...)
+
+	for _, containedKey := range product.ContainedProducts {
+		*nodes++
+		if *nodes > maxCompositionNodes {
+			return nil, fmt.Errorf("Composition has more than %d products", maxCompositionNodes)
+		}
+		jsonAsBytes, err := stub.GetState(containedKey)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get %s: %s", containedKey, err.Error())
+		}
+		contained := new(Product)
+		child := &CompositionNode{containedKey, 0, "", []*CompositionNode{}}
+		switch {
+		case jsonAsBytes == nil:
+			child.Problem = "NOT_FOUND"
+		case json.Unmarshal(jsonAsBytes, contained) != nil || contained.DocType != "product":
+			child.Problem = "WRONG_DOCTYPE"
+		case contains(path, containedKey):
+			child.Status = contained.Status
+			child.Problem = "CYCLE"
+		case depth+1 > maxDepth:
+			child.Status = contained.Status
+			child.Problem = "TOO_DEEP"
+		default:
+			child, err = c.expandComposition(stub, containedKey, contained, path, depth+1, maxDepth, nodes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		node.ContainedProducts = append(node.ContainedProducts, child)
+	}
+	return node, nil
+}
+
+// productVersions returns key and the keys of all versions the product
+// stored under key supersedes, newest first
+func (c *ProductChaincode) productVersions(stub shim.ChaincodeStubInterface, key string, product *Product) ([]string, error) {
+	versions := []string{key}
+	for len(product.Supersedes) > 0 && !contains(versions, product.Supersedes) {
+		older, err := c.getProduct(stub, product.Supersedes)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, product.Supersedes)
+		product = older
+	}
+	return versions, nil
+}
@@ -0,0 +1,80 @@
+package viridian
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// configKey is the key under which the chaincode configuration is stored in state
+const configKey = "config"
+
+// Config holds the parameters of the chaincode that can be set on
+// instantiation or upgrade. It is stored in state (not in memory), so that
+// all peers use the same values.
+type Config struct {
+	ReviewersPerAsset int `json:"reviewersPerAsset"` // number of users appointed to review an asset
+	ReviewQuorum      int `json:"reviewQuorum"`      // number of approvals needed to accept an asset
+
+	MaxReviewsPerSubmitter int `json:"maxReviewsPerSubmitter"` // number of assets of the same submitter a user is appointed to review at most
+
+	MaxCompositionDepth int `json:"maxCompositionDepth"` // how deep contained products may be nested
+	MaxQueryLimit       int `json:"maxQueryLimit"`       // number of results an ad hoc query returns at most
+
+	RequireUUIDKeys bool `json:"requireUuidKeys"` // whether the keys of new assets must be UUIDs (version 4)
+}
+
+// defaultConfig is used as long as no configuration has been stored
+var defaultConfig = Config{
+	ReviewersPerAsset: 5,
+	ReviewQuorum:      3,
+
+	MaxReviewsPerSubmitter: 50,
+
+	MaxCompositionDepth: 10,
+	MaxQueryLimit:       100,
+}
+
+// initConfig stores the configuration passed as JSON to `init`, e.g.
+// `{"Args":["init","{\"reviewersPerAsset\": 5, \"reviewQuorum\": 3}"]}`.
+// Without argument, a previously stored configuration is kept.
+func initConfig(stub shim.ChaincodeStubInterface, args []string) error {
+	if len(args) == 0 || len(args[0]) == 0 {
+		return nil
+	}
+	config := defaultConfig
+	err := json.Unmarshal([]byte(args[0]), &config)
+	if err != nil {
+		return errors.New("Argument of init must be a JSON object with the chaincode configuration: " + err.Error())
+	}
+	if config.ReviewQuorum < 1 || config.ReviewQuorum > config.ReviewersPerAsset {
+		return errors.New("'reviewQuorum' must be between 1 and 'reviewersPerAsset'")
+	}
+	if config.MaxReviewsPerSubmitter < 1 {
+		return errors.New("'maxReviewsPerSubmitter' must be at least 1")
+	}
+	if config.MaxCompositionDepth < 1 {
+		return errors.New("'maxCompositionDepth' must be at least 1")
+	}
+	if config.MaxQueryLimit < 1 {
+		return errors.New("'maxQueryLimit' must be at least 1")
+	}
+	return putAsset(stub, configKey, &config)
+}
+
+// getConfig reads the chaincode configuration from state
+func getConfig(stub shim.ChaincodeStubInterface) (*Config, error) {
+	config := defaultConfig
+	jsonAsBytes, err := stub.GetState(configKey)
+	if err != nil {
+		return nil, errors.New("Failed to get configuration: " + err.Error())
+	}
+	if jsonAsBytes != nil {
+		err = json.Unmarshal(jsonAsBytes, &config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &config, nil
+}
@@ -18,6 +18,18 @@ const (
 	Rejected
 )
 
+var statusNames = map[string]Status{
+	"PRELIMINARY": Preliminary,
+	"ACTIVE":      Active,
+	"OUTDATED":    Outdated,
+	"DELETED":     Deleted,
+	"REJECTED":    Rejected,
+}
+
+// DeletionRequest is the value of `supersededBy` while the deletion of an
+// asset is under review
+const DeletionRequest = "DELETION"
+
 // Score is the sustainability rating of a scorable asset, either an "atomic" one (in a Rating), but usually, in a ScorableAsset, the averaged one
 type Score struct {
 	Environment   int `json:"environment"`   // range=[-100,100], air pollution, water pollution, soil pollution, waste, harmful substances released into environment etc., without greenhouse gases
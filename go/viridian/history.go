@@ -0,0 +1,130 @@
+package viridian
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// History of assets
+//
+// An edit stores the new version of an asset under a new key, so the ledger's
+// history of one key only covers one version. The history queries follow the
+// `supersedes`/`supersededBy` chain of the requested key and merge the
+// histories of all versions into one, ordered by time.
+
+// HistoryEntry is one committed state of one version of an asset
+type HistoryEntry struct {
+	Key       string          `json:"key"` // key of the version
+	TxID      string          `json:"txId"`
+	Timestamp time.Time       `json:"timestamp"`
+	IsDelete  bool            `json:"isDelete"`
+	Value     json.RawMessage `json:"value"` // null if isDelete
+}
+
+// getHistory returns the merged history of all versions of the asset stored
+// under the key passed as the only argument, if it is of the given docType
+func getHistory(stub shim.ChaincodeStubInterface, docType string, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+	key := args[0]
+	_, err := getAssetJSON(stub, key, docType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fmt.Println("- start getHistory: " + key)
+	versions, err := versionChain(stub, key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	history := []HistoryEntry{}
+	for _, version := range versions {
+		entries, err := keyHistory(stub, version)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		history = append(history, entries...)
+	}
+	// Versions are ordered from old to new and each key history is ordered by
+	// time, so a stable sort keeps the order of the entries of the same time
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+
+	jsonAsBytes, err := json.Marshal(history)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Println("- end getHistory")
+	return shim.Success(jsonAsBytes)
+}
+
+// versionChain returns the keys of all versions of the asset stored under
+// key, from the oldest to the newest: the versions key supersedes and the
+// versions superseding key
+func versionChain(stub shim.ChaincodeStubInterface, key string) ([]string, error) {
+	asset, err := getUpdatable(stub, key)
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{key}
+	for older := asset.updatableAsset().Supersedes; len(older) > 0 && !contains(versions, older); {
+		versions = append([]string{older}, versions...)
+		olderAsset, err := getUpdatable(stub, older)
+		if err != nil {
+			return nil, err
+		}
+		older = olderAsset.updatableAsset().Supersedes
+	}
+	for newer := asset.updatableAsset().SupersededBy; len(newer) > 0 && newer != DeletionRequest && !contains(versions, newer); {
+		versions = append(versions, newer)
+		newerAsset, err := getUpdatable(stub, newer)
+		if err != nil {
+			return nil, err
+		}
+		newer = newerAsset.updatableAsset().SupersededBy
+	}
+	return versions, nil
+}
+
+// keyHistory returns the entries of the ledger's history of key
+func keyHistory(stub shim.ChaincodeStubInterface, key string) ([]HistoryEntry, error) {
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, errors.New("Failed to get history of " + key + ": " + err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var entries []HistoryEntry
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		entry := HistoryEntry{key, modification.TxId, time.Time{}, modification.IsDelete, nil}
+		if modification.Timestamp != nil {
+			entry.Timestamp = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC()
+		}
+		// if it was a delete operation on given key, then we need to set the
+		// corresponding value null. Else, we will write the response.Value
+		// as-is (as the Value itself a JSON, in the current shape)
+		if modification.IsDelete {
+			entry.Value = json.RawMessage("null")
+		} else {
+			value, err := currentShape(modification.Value)
+			if err != nil {
+				return nil, err
+			}
+			entry.Value = json.RawMessage(value)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -1,6 +1,15 @@
 package viridian
 
-import "time"
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// InformationChaincode is the chaincode associated with information
+type InformationChaincode struct {
+}
 
 // InfoCategory is an enum used in type Information
 type InfoCategory int
@@ -79,3 +88,37 @@ type Information struct {
 	Sources     []Source     `json:"sources"`
 	Weight      int32        `json:"weight"`
 }
+
+// queryStringForTarget returns the rich query for the information about a scorable asset
+func (c *InformationChaincode) queryStringForTarget(target string) (string, error) {
+	return newSelector("information").eq("target", target).queryStringWithIndex(indexTargetDoc, indexTarget)
+}
+
+// QueryInformationByTarget queries for the information about the product,
+// producer or label with the given key
+func (c *InformationChaincode) QueryInformationByTarget(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//                      0
+	// "product-1fcc2c43-12a1-4451-ac56-dd73099b3f34"
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	queryString, err := c.queryStringForTarget(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryInformationByTargetWithPagination is the paginated variant of QueryInformationByTarget
+func (c *InformationChaincode) QueryInformationByTargetWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//          0            1     2
+	// "product-1fcc...",  "20", bookmark ("" for the first page)
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	queryString, err := c.queryStringForTarget(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
@@ -0,0 +1,64 @@
+package viridian
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Asset keys
+//
+// The client chooses the key of a new asset (usually a random UUID) and the
+// chaincode stores the asset under docType + "-" + key, e.g.
+// "product-8a259c61-6825-...". The key must be new: an existing asset is
+// never overwritten by adding another one under the same key.
+
+// maxKeyLength is the maximum length of a key chosen by the client
+const maxKeyLength = 64
+
+var (
+	keyPattern    = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	uuidv4Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+)
+
+// newAssetKey checks the key chosen by the client for a new asset of the
+// docType and returns the key the asset is stored under. The key must be
+// given, consist of letters, digits and hyphens only, be a lowercase UUID
+// (version 4) if the configuration requires it and must not be in use yet.
+func newAssetKey(stub shim.ChaincodeStubInterface, docType string, key string) (string, error) {
+	assetType := strings.ToUpper(docType[:1]) + docType[1:]
+	if len(key) == 0 {
+		return "", errors.New(assetType + " key not provided")
+	}
+	if len(key) > maxKeyLength || !keyPattern.MatchString(key) {
+		return "", errors.New(assetType + " key '" + key + "' is malformed: it must consist of at most 64 letters, digits and hyphens")
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return "", err
+	}
+	if config.RequireUUIDKeys && !uuidv4Pattern.MatchString(key) {
+		return "", errors.New(assetType + " key '" + key + "' is malformed: it must be a lowercase UUID (version 4), e.g. 8a259c61-6825-4b3c-9a1e-2f0b5c1d7e34")
+	}
+
+	stateKey := docType + "-" + key
+	existing, err := stub.GetState(stateKey)
+	if err != nil {
+		return "", errors.New("Failed to get " + docType + ": " + err.Error())
+	}
+	if existing != nil {
+		return "", errors.New(assetType + " with key " + stateKey + " already exists")
+	}
+	return stateKey, nil
+}
+
+// isAssetKey reports whether key could be the key of an asset of the docType,
+// i.e. docType + "-" followed by a key newAssetKey accepts. It checks
+// references to assets the chaincode does not store yet, such as product
+// categories.
+func isAssetKey(docType string, key string) bool {
+	suffix := strings.TrimPrefix(key, docType+"-")
+	return suffix != key && len(suffix) > 0 && len(suffix) <= maxKeyLength && keyPattern.MatchString(suffix)
+}
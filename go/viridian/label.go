@@ -1,12 +1,27 @@
 package viridian
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// LabelChaincode is the chaincode associated with labels
+type LabelChaincode struct {
+}
+
 // LabelLocaleData is the locale-specific (language-specific) part of a label
 type LabelLocaleData struct {
-	Lang        string   `json:"lang"` // regex=/^[a-z]{2}$/ // ISO language code according to https://en.wikipedia.org/wiki/ISO_639-1, there should be only one locale data for each language
+	Lang        string   `json:"lang"` // ISO 639-1 language code, optionally with a BCP 47 region subtag, e.g. "de" or "de-CH" (see checkLocales), there should be only one locale data for each language
 	Name        string   `json:"name"`
 	Description string   `json:"description"` // optional
-	URL         string   `json:"url"`         // regex=/^[a-z]+:\/\/[^ ]+$/ optional
-	Categories  []string `json:"categories"`
+	Categories  []string `json:"categories"`  // optional
+	LogoURLs    []string `json:"logoUrls"`    // https, http or ipfs URLs (see normalizeURLs) optional
+	URLs        []string `json:"urls"`        // https, http or ipfs URLs (see normalizeURLs) optional
 }
 
 // Label is the asset representing a sustainability label that can be associated with a product or a producer, e.g. "Organic" or "Fairtrade"
@@ -14,5 +29,288 @@ type Label struct {
 	ScorableAsset
 	DocType string            `json:"docType"` // docType is used to distinguish the various types of objects in state database
 	Locales []LabelLocaleData `json:"locales"`
-	Version string            `json:"version"` // optional // label IDs
+	Version string            `json:"version"` // optional // version of the label's criteria, e.g. "2019"
+}
+
+// ex:
+// &Label{
+//   ScorableAsset{...},
+//   DocType: "label",
+//   Locales: []LabelLocaleData{
+//     LabelLocaleData{
+//       Lang: "de",
+//       Name: "Bio Suisse Knospe",
+//       Description: "Label für Produkte aus biologischer Landwirtschaft",
+//       Categories: []string{"Bio"},
+//       LogoURLs: []string{"ipfs://QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"},
+//       URLs: []string{"https://www.bio-suisse.ch/"},
+//     },
+//   },
+//   Version: "2019",
+// }
+
+// AddLabel creates a new label and stores it into chaincode state
+func (c *LabelChaincode) AddLabel(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0                     1                                                  2
+	// Key,                 Locales,                                           Version
+	// "31d3a05e-fb10-...", `[{"lang": "de", "name": "Bio Suisse Knospe", ...}]`, "2019" or ""
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
+	fmt.Println("- start add label")
+	key, label, err := c.newLabelFromArgs(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = putAsset(stub, key, label)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Appoint reviewers ====
+	err = requestReview(stub, key, "", label.CreatedBy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fmt.Println("- end add label")
+	return shim.Success(nil)
+}
+
+// EditLabel creates a new version of an active label. The new version is
+// stored under a new key and supersedes the old version, which stays active
+// until the review of the new version is closed.
+func (c *LabelChaincode) EditLabel(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0                            1                              2-4
+	// Old label key,              Change reason,                 same as for addLabel (new key, locales, version)
+	// "label-31d3a05e-fb10-...", "New criteria published.",     "5e0e2ab7-0d0c-...", `[{"lang": "de", ...}]`, "2020"
+	if len(args) != 5 {
+		return shim.Error("Incorrect number of arguments. Expecting 5.")
+	}
+
+	fmt.Println("- start edit label")
+
+	// === Arg 0: Old key ===
+	oldKey := args[0]
+	if len(oldKey) == 0 {
+		return shim.Error("Old label key not provided")
+	}
+
+	// === Arg 1: Change reason ===
+	changeReason := args[1]
+	if len(changeReason) == 0 {
+		return shim.Error("Change reason not provided")
+	}
+
+	// ==== Check the old label ====
+	oldLabel, err := c.getLabel(stub, oldKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if oldLabel.Status != Active {
+		return shim.Error("Label " + oldKey + " is not active and cannot be edited")
+	}
+	if len(oldLabel.SupersededBy) > 0 {
+		return shim.Error("Label " + oldKey + " already has an edit or deletion pending: " + oldLabel.SupersededBy)
+	}
+
+	// === Args 2-4: New label ===
+	key, label, err := c.newLabelFromArgs(stub, args[2:])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Link the two versions ====
+	label.UpdatedBy = label.CreatedBy
+	label.UpdatedAt = label.CreatedAt
+	label.CreatedBy = oldLabel.CreatedBy
+	label.CreatedAt = oldLabel.CreatedAt
+	label.Score = oldLabel.Score
+	label.Supersedes = oldKey
+	label.ChangeReason = changeReason
+	oldLabel.SupersededBy = key
+
+	// === Save both versions to state ===
+	err = putAsset(stub, key, label)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAsset(stub, oldKey, oldLabel)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Appoint reviewers ====
+	err = requestReview(stub, key, "", label.CreatedBy, label.UpdatedBy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fmt.Println("- end edit label")
+	return shim.Success(nil)
+}
+
+// ReadLabel returns the label stored under a key
+func (c *LabelChaincode) ReadLabel(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//  0
+	// Label key
+	// "label-31d3a05e-fb10-..."
+	return readAsset(stub, "label", args)
+}
+
+// GetHistoryForLabel returns the history of all versions of a label
+func (c *LabelChaincode) GetHistoryForLabel(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//  0
+	// Label key
+	// "label-31d3a05e-fb10-..."
+	return getHistory(stub, "label", args)
+}
+
+// queryStringForName returns the rich query for the labels with a locale
+// matching the name, optionally only in the given language (see localeName)
+func (c *LabelChaincode) queryStringForName(name string, lang string, match string) (string, error) {
+	locale, err := localeName(name, lang, match)
+	if err != nil {
+		return "", err
+	}
+	return newSelector("label").elemMatch("locales", locale).queryStringWithIndex(indexDocTypeDoc, indexDocType)
+}
+
+// QueryLabelsByName queries for labels that have a locale with the given
+// name, optionally only in the given language. By default, the name must be
+// equal, the match argument allows case-insensitive prefix or substring search.
+// Only available on state databases that support rich query (e.g. CouchDB)
+func (c *LabelChaincode) QueryLabelsByName(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//       0                            1                            2
+	// label name query string   lang: e.g. "de" (optional)   match: "EXACT", "PREFIX" or "SUBSTRING" (optional)
+	if len(args) < 1 || len(args) > 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 to 3")
+	}
+	queryString, err := c.queryStringForName(args[0], optionalArg(args, 1), optionalArg(args, 2))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryLabelsByNameWithPagination is the paginated variant of QueryLabelsByName
+func (c *LabelChaincode) QueryLabelsByNameWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//       0                              1                  2                                3     4
+	// label name query string   lang: e.g. "de" or "",     match: e.g. "PREFIX" or "",    "20", bookmark ("" for the first page)
+	if len(args) != 5 {
+		return shim.Error("Incorrect number of arguments. Expecting 5")
+	}
+	queryString, err := c.queryStringForName(args[0], args[1], args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// GetLabelsByRangeWithPagination returns one page of the labels with keys
+// between a start key (inclusive) and an end key (exclusive)
+func (c *LabelChaincode) GetLabelsByRangeWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return getRangeWithPagination(stub, "label", args)
+}
+
+// newLabelFromArgs performs the input sanitation of the arguments shared by
+// addLabel and editLabel and returns the new preliminary label together with
+// the key it shall be stored under
+func (c *LabelChaincode) newLabelFromArgs(stub shim.ChaincodeStubInterface, args []string) (string, *Label, error) {
+	createdBy, err := getRegisteredUser(stub)
+	if err != nil {
+		return "", nil, err
+	}
+	createdAt, err := txTime(stub)
+	if err != nil {
+		return "", nil, err
+	}
+	updatedBy := ""
+	updatedAt, _ := time.Parse("2005-12-31", "1776-03-09")
+	supersedes := ""
+	supersededBy := ""
+	changeReason := ""
+	score := Score{Environment: 0, Climate: 0, Society: 0, Health: 0, Economy: 0}
+
+	// === Arg 0: Key ===
+	docType := "label"
+	key, err := newAssetKey(stub, docType, args[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	// === Arg 1: Locales ===
+	var locales []LabelLocaleData
+	err = json.Unmarshal([]byte(args[1]), &locales)
+	if err != nil {
+		return "", nil, errors.New("'locales' must be a string with " +
+			"a JSON list of objects with keys 'lang', 'name', 'description', 'categories', " +
+			"'logoUrls', 'urls', where each contains a string, except 'categories', " +
+			"'logoUrls' and 'urls' contain a list of strings.")
+	}
+
+	// === Arg 2: Version ===
+	// optional
+	version := args[2]
+
+	label := &Label{
+		ScorableAsset{
+			UpdatableAsset{
+				ReviewableAsset{createdBy, createdAt, Preliminary},
+				updatedBy, updatedAt, supersedes, supersededBy, changeReason},
+			score},
+		docType, locales, version}
+
+	// ==== Check the locales ====
+	err = checkLocales(label)
+	if err != nil {
+		return "", nil, err
+	}
+	err = normalizeURLs(label)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, label, nil
+}
+
+// localeFields returns lang and name of each locale of the label
+func (l *Label) localeFields() []localeFields {
+	fields := make([]localeFields, len(l.Locales))
+	for i, locale := range l.Locales {
+		fields[i] = localeFields{locale.Lang, locale.Name}
+	}
+	return fields
+}
+
+// urlFields returns the logo URLs and URLs of each locale of the label
+func (l *Label) urlFields() []urlField {
+	var fields []urlField
+	for i := range l.Locales {
+		locale := &l.Locales[i]
+		for j := range locale.LogoURLs {
+			fields = append(fields, urlField{fmt.Sprintf("locales[%d].logoUrls[%d]", i, j), &locale.LogoURLs[j]})
+		}
+		for j := range locale.URLs {
+			fields = append(fields, urlField{fmt.Sprintf("locales[%d].urls[%d]", i, j), &locale.URLs[j]})
+		}
+	}
+	return fields
+}
+
+// getLabel reads the label stored under key from chaincode state
+func (c *LabelChaincode) getLabel(stub shim.ChaincodeStubInterface, key string) (*Label, error) {
+	jsonAsBytes, err := getAssetJSON(stub, key, "label")
+	if err != nil {
+		return nil, err
+	}
+	label := new(Label)
+	err = json.Unmarshal(jsonAsBytes, label)
+	if err != nil {
+		return nil, errors.New(key + " is not a valid label")
+	}
+	return label, nil
 }
@@ -0,0 +1,181 @@
+package viridian
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Lifecycle of updatable assets
+//
+// A new asset starts as Preliminary. An edit creates a new Preliminary
+// version that `supersedes` the Active old version, while the old version has
+// the new key in `supersededBy`. A deletion request keeps the asset Active
+// and only sets `supersededBy` to DeletionRequest, while requester and reason
+// are kept in the reviews. In all cases, the asset is in the 'review queue'
+// until the review is closed with closeReview.
+
+// updatable is implemented by all assets that embed UpdatableAsset
+type updatable interface {
+	updatableAsset() *UpdatableAsset
+}
+
+func (a *UpdatableAsset) updatableAsset() *UpdatableAsset {
+	return a
+}
+
+// newUpdatable returns an empty asset of the given docType
+func newUpdatable(docType string) (updatable, error) {
+	switch docType {
+	case "product":
+		return new(Product), nil
+	case "producer":
+		return new(Producer), nil
+	case "label":
+		return new(Label), nil
+	}
+	return nil, errors.New("Assets of type '" + docType + "' are not updatable")
+}
+
+// getUpdatable reads the updatable asset stored under key from chaincode state
+func getUpdatable(stub shim.ChaincodeStubInterface, key string) (updatable, error) {
+	jsonAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.New("Failed to get asset: " + err.Error())
+	}
+	if jsonAsBytes == nil {
+		return nil, errors.New("Asset " + key + " does not exist")
+	}
+	var doc struct {
+		DocType string `json:"docType"`
+	}
+	err = json.Unmarshal(jsonAsBytes, &doc)
+	if err != nil {
+		return nil, errors.New(key + " is not a valid asset")
+	}
+	asset, err := newUpdatable(doc.DocType)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(jsonAsBytes, asset)
+	if err != nil {
+		return nil, errors.New(key + " is not a valid " + doc.DocType)
+	}
+	return asset, nil
+}
+
+// indexed is implemented by assets that maintain composite key index entries
+type indexed interface {
+	indexEntries(stub shim.ChaincodeStubInterface, key string) ([]string, error)
+}
+
+// putIndexEntries saves the index entries of the asset stored under key to state
+func putIndexEntries(stub shim.ChaincodeStubInterface, key string, asset indexed) error {
+	indexKeys, err := asset.indexEntries(stub, key)
+	if err != nil {
+		return err
+	}
+	for _, indexKey := range indexKeys {
+		//  Only the key name is needed, no need to store a duplicate copy of the asset.
+		//  Note - passing a 'nil' value will effectively delete the key from state, therefore we pass null character as value
+		err = stub.PutState(indexKey, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delIndexEntriesIfRetired removes the index entries of the asset stored
+// under key once it is rejected, deleted or outdated
+func delIndexEntriesIfRetired(stub shim.ChaincodeStubInterface, key string, asset updatable) error {
+	status := asset.updatableAsset().Status
+	i, ok := asset.(indexed)
+	if !ok || (status != Rejected && status != Deleted && status != Outdated) {
+		return nil
+	}
+	indexKeys, err := i.indexEntries(stub, key)
+	if err != nil {
+		return err
+	}
+	for _, indexKey := range indexKeys {
+		err = stub.DelState(indexKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putAsset marshals the asset to JSON and saves it to chaincode state
+func putAsset(stub shim.ChaincodeStubInterface, key string, asset interface{}) error {
+	jsonAsBytes, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, jsonAsBytes)
+}
+
+// closeReview applies the outcome of a closed review to the reviewed asset
+// stored under key (and to the version it supersedes, if any):
+//   - a new asset becomes Active (approved) or Rejected (not approved)
+//   - a new version becomes Active and the old version Outdated (approved), or
+//     the new version becomes Rejected and the old version's `supersededBy`
+//     is cleared (not approved)
+//   - an asset with a pending deletion becomes Deleted with requester, time
+//     and reason of the request taken from review (approved), or its
+//     `supersededBy` is cleared (not approved)
+func closeReview(stub shim.ChaincodeStubInterface, key string, approved bool, review *Review) error {
+	asset, err := getUpdatable(stub, key)
+	if err != nil {
+		return err
+	}
+	a := asset.updatableAsset()
+
+	switch {
+	case a.Status == Active && a.SupersededBy == DeletionRequest:
+		if approved {
+			a.Status = Deleted
+			a.UpdatedBy = review.RequestedBy
+			a.UpdatedAt = review.RequestedAt
+			a.ChangeReason = review.ChangeReason
+		} else {
+			a.SupersededBy = ""
+		}
+	case a.Status == Preliminary:
+		if approved {
+			a.Status = Active
+		} else {
+			a.Status = Rejected
+		}
+		if len(a.Supersedes) > 0 {
+			oldAsset, err := getUpdatable(stub, a.Supersedes)
+			if err != nil {
+				return err
+			}
+			old := oldAsset.updatableAsset()
+			if approved {
+				old.Status = Outdated
+			} else {
+				old.SupersededBy = ""
+			}
+			err = putAsset(stub, a.Supersedes, oldAsset)
+			if err != nil {
+				return err
+			}
+			err = delIndexEntriesIfRetired(stub, a.Supersedes, oldAsset)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("Asset " + key + " has no review pending")
+	}
+
+	err = putAsset(stub, key, asset)
+	if err != nil {
+		return err
+	}
+	return delIndexEntriesIfRetired(stub, key, asset)
+}
@@ -0,0 +1,175 @@
+// Package link validates and normalizes the URLs stored in assets: web pages
+// (https and http) and files on IPFS (ipfs://<CID>/<path>).
+package link
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+// MaxLength is the maximum length of a normalized URL
+const MaxLength = 2048
+
+// Schemes are the URL schemes that assets may link to
+var Schemes = []string{"https", "http", "ipfs"}
+
+// Problems reported by an Error
+const (
+	Malformed         = "MALFORMED"
+	UnsupportedScheme = "UNSUPPORTED_SCHEME"
+	TooLong           = "TOO_LONG"
+	InvalidCID        = "INVALID_CID"
+)
+
+// Error is returned for a URL that cannot be stored
+type Error struct {
+	Problem string // Malformed, UnsupportedScheme, TooLong or InvalidCID
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(problem string, format string, args ...interface{}) *Error {
+	return &Error{problem, fmt.Sprintf(format, args...)}
+}
+
+// Normalize validates a URL and returns its normalized form: the scheme and
+// the host of web URLs are lowercased, default ports are removed, an empty
+// path becomes "/" and characters that must be escaped are escaped. IPFS URLs
+// must name a valid CID (version 0, or version 1 in base32 or base58btc) and
+// must not have a port, user info or query.
+func Normalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", newError(Malformed, "URL %q is malformed: %s", raw, err)
+	}
+	if !u.IsAbs() {
+		return "", newError(Malformed, "URL %q must start with a scheme, e.g. https://", raw)
+	}
+	if !contains(Schemes, u.Scheme) {
+		return "", newError(UnsupportedScheme, "URL %q has the scheme %q, expected one of %s", raw, u.Scheme, strings.Join(Schemes, ", "))
+	}
+	if len(u.Opaque) > 0 {
+		return "", newError(Malformed, "URL %q must have \"://\" after the scheme", raw)
+	}
+	if u.User != nil {
+		return "", newError(Malformed, "URL %q must not contain user info", raw)
+	}
+	if len(u.Hostname()) == 0 {
+		return "", newError(Malformed, "URL %q has no host", raw)
+	}
+
+	if u.Scheme == "ipfs" {
+		if len(u.Port()) > 0 || len(u.RawQuery) > 0 {
+			return "", newError(Malformed, "IPFS URL %q must not have a port or query", raw)
+		}
+		err := checkCID(u.Host)
+		if err != nil {
+			return "", newError(InvalidCID, "IPFS URL %q has an invalid CID: %s", raw, err)
+		}
+	} else {
+		host, port := strings.ToLower(u.Hostname()), u.Port()
+		if u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443" {
+			port = ""
+		}
+		u.Host = host
+		if len(port) > 0 {
+			u.Host += ":" + port
+		}
+		if len(u.Path) == 0 {
+			u.Path = "/"
+		}
+	}
+
+	normalized := u.String()
+	if len(normalized) > MaxLength {
+		return "", newError(TooLong, "URL %q is longer than %d characters", raw, MaxLength)
+	}
+	return normalized, nil
+}
+
+// base58Alphabet is the Bitcoin base58 alphabet used by CIDs
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// checkCID checks the syntax of a content identifier: a version 0 CID is the
+// base58 encoded SHA-256 multihash ("Qm..."), a version 1 CID starts with
+// the multibase prefix, "b" for base32 ("bafy...") or "z" for base58btc,
+// followed by the version 1
+func checkCID(cid string) error {
+	switch {
+	case len(cid) == 46 && strings.HasPrefix(cid, "Qm"):
+		multihash, err := decodeBase58(cid)
+		if err != nil {
+			return err
+		}
+		if len(multihash) != 34 || multihash[0] != 0x12 || multihash[1] != 0x20 {
+			return errors.New("version 0 CIDs must be SHA-256 multihashes")
+		}
+		return nil
+	case strings.HasPrefix(cid, "b"):
+		decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(cid[1:]))
+		if err != nil {
+			return errors.New("invalid base32")
+		}
+		return checkCIDv1(decoded)
+	case strings.HasPrefix(cid, "z"):
+		decoded, err := decodeBase58(cid[1:])
+		if err != nil {
+			return err
+		}
+		return checkCIDv1(decoded)
+	}
+	return errors.New("must be a version 0 CID (Qm...) or a version 1 CID in base32 (b...) or base58btc (z...)")
+}
+
+// checkCIDv1 checks a decoded version 1 CID: the version, the codec and the
+// multihash with the hash function and the digest of the given length
+func checkCIDv1(cid []byte) error {
+	var header [4]uint64 // version, codec, hash function, digest length
+	for i := range header {
+		value, n := binary.Uvarint(cid)
+		if n <= 0 {
+			return errors.New("truncated")
+		}
+		header[i], cid = value, cid[n:]
+	}
+	if header[0] != 1 {
+		return errors.New("not a version 1 CID")
+	}
+	if header[3] != uint64(len(cid)) {
+		return errors.New("digest length does not match")
+	}
+	return nil
+}
+
+// decodeBase58 decodes a base58 string without checksum
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not a base58 character", r)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	leadingZeros := len(s) - len(strings.TrimLeft(s, "1"))
+	return append(make([]byte, leadingZeros), n.Bytes()...), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
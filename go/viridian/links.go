@@ -0,0 +1,61 @@
+package viridian
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/chaincode/viridian/go/viridian/link"
+)
+
+// URL validation
+//
+// Products, producers and labels link to web pages and images. Before such
+// an asset is written, every URL is validated and replaced by its normalized
+// form (see package link): only https, http and ipfs URLs are accepted.
+
+// urlField is a field of an asset holding a URL
+type urlField struct {
+	field string // e.g. "locales[0].imageUrl" or "locales[1].urls[0]"
+	url   *string
+}
+
+// linking is implemented by assets that have URL fields
+type linking interface {
+	urlFields() []urlField
+}
+
+// URLError lists all invalid URLs of an asset
+type URLError struct {
+	Invalid []InvalidField `json:"invalidFields"`
+}
+
+func (e *URLError) Error() string {
+	jsonAsBytes, _ := json.Marshal(e)
+	return fmt.Sprintf("%d invalid URL(s): %s", len(e.Invalid), jsonAsBytes)
+}
+
+// normalizeURLs normalizes the URLs of asset and returns a *URLError listing
+// every URL that is empty or invalid
+func normalizeURLs(asset linking) error {
+	var invalid []InvalidField
+	for _, f := range asset.urlFields() {
+		if len(*f.url) == 0 {
+			invalid = append(invalid, InvalidField{f.field, "", "MISSING", ""})
+			continue
+		}
+		normalized, err := link.Normalize(*f.url)
+		if err != nil {
+			problem := link.Malformed
+			if e, ok := err.(*link.Error); ok {
+				problem = e.Problem
+			}
+			invalid = append(invalid, InvalidField{f.field, *f.url, problem, err.Error()})
+			continue
+		}
+		*f.url = normalized
+	}
+	if len(invalid) > 0 {
+		return &URLError{invalid}
+	}
+	return nil
+}
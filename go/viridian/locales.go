@@ -0,0 +1,122 @@
+package viridian
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Locale validation
+//
+// Products, labels and producers hold their language-specific data in a list
+// of locales. Before such an asset is written, its locales are checked: there
+// must be at least one, each must have a name and a lang, which is an ISO
+// 639-1 language code, optionally followed by a BCP 47 region subtag (e.g.
+// "de" or "de-CH"), and no two locales may have the same lang.
+
+// localeFields are the fields every locale has, whatever the asset
+type localeFields struct {
+	lang string
+	name string
+}
+
+// localized is implemented by assets that have locales
+type localized interface {
+	localeFields() []localeFields
+}
+
+// InvalidField is a field of an asset with an invalid value
+type InvalidField struct {
+	Field   string `json:"field"` // e.g. "locales[1].lang"
+	Value   string `json:"value"`
+	Problem string `json:"problem"`           // MISSING, MALFORMED, UNKNOWN_LANGUAGE, DUPLICATE, UNKNOWN_CURRENCY or a problem of package link
+	Message string `json:"message,omitempty"` // what is wrong with a MALFORMED value
+}
+
+// LocaleError lists all invalid fields of the locales of an asset
+type LocaleError struct {
+	Invalid []InvalidField `json:"invalidFields"`
+}
+
+func (e *LocaleError) Error() string {
+	jsonAsBytes, _ := json.Marshal(e)
+	return fmt.Sprintf("%d invalid locale field(s): %s", len(e.Invalid), jsonAsBytes)
+}
+
+// langPattern matches a language code with an optional region subtag, which
+// is an ISO 3166-1 country code or a UN M.49 area code (see BCP 47)
+var langPattern = regexp.MustCompile(`^([a-z]{2})(-([A-Z]{2}|[0-9]{3}))?$`)
+
+// checkLocales returns a *LocaleError listing every invalid field of the
+// locales of asset
+func checkLocales(asset localized) error {
+	locales := asset.localeFields()
+	if len(locales) == 0 {
+		return &LocaleError{[]InvalidField{{"locales", "", "MISSING", ""}}}
+	}
+	var invalid []InvalidField
+	seen := make(map[string]bool)
+	for i, locale := range locales {
+		field := fmt.Sprintf("locales[%d]", i)
+		if problem := langProblem(locale.lang); len(problem) > 0 {
+			invalid = append(invalid, InvalidField{field + ".lang", locale.lang, problem, ""})
+		} else if seen[locale.lang] {
+			invalid = append(invalid, InvalidField{field + ".lang", locale.lang, "DUPLICATE", ""})
+		}
+		seen[locale.lang] = true
+		if len(strings.TrimSpace(locale.name)) == 0 {
+			invalid = append(invalid, InvalidField{field + ".name", locale.name, "MISSING", ""})
+		}
+	}
+	if len(invalid) > 0 {
+		return &LocaleError{invalid}
+	}
+	return nil
+}
+
+// langProblem returns why lang is invalid, or "" if it is fine
+func langProblem(lang string) string {
+	if len(lang) == 0 {
+		return "MISSING"
+	}
+	match := langPattern.FindStringSubmatch(lang)
+	if match == nil {
+		return "MALFORMED"
+	}
+	if !iso639Codes[match[1]] {
+		return "UNKNOWN_LANGUAGE"
+	}
+	return ""
+}
+
+// iso639Codes holds the ISO 639-1 language codes, see
+// https://www.loc.gov/standards/iso639-2/php/code_list.php
+var iso639Codes = map[string]bool{
+	"aa": true, "ab": true, "ae": true, "af": true, "ak": true, "am": true, "an": true, "ar": true, "as": true, "av": true, "ay": true, "az": true,
+	"ba": true, "be": true, "bg": true, "bi": true, "bm": true, "bn": true, "bo": true, "br": true, "bs": true,
+	"ca": true, "ce": true, "ch": true, "co": true, "cr": true, "cs": true, "cu": true, "cv": true, "cy": true,
+	"da": true, "de": true, "dv": true, "dz": true,
+	"ee": true, "el": true, "en": true, "eo": true, "es": true, "et": true, "eu": true,
+	"fa": true, "ff": true, "fi": true, "fj": true, "fo": true, "fr": true, "fy": true,
+	"ga": true, "gd": true, "gl": true, "gn": true, "gu": true, "gv": true,
+	"ha": true, "he": true, "hi": true, "ho": true, "hr": true, "ht": true, "hu": true, "hy": true, "hz": true,
+	"ia": true, "id": true, "ie": true, "ig": true, "ii": true, "ik": true, "io": true, "is": true, "it": true, "iu": true,
+	"ja": true, "jv": true,
+	"ka": true, "kg": true, "ki": true, "kj": true, "kk": true, "kl": true, "km": true, "kn": true, "ko": true, "kr": true, "ks": true, "ku": true, "kv": true, "kw": true, "ky": true,
+	"la": true, "lb": true, "lg": true, "li": true, "ln": true, "lo": true, "lt": true, "lu": true, "lv": true,
+	"mg": true, "mh": true, "mi": true, "mk": true, "ml": true, "mn": true, "mr": true, "ms": true, "mt": true, "my": true,
+	"na": true, "nb": true, "nd": true, "ne": true, "ng": true, "nl": true, "nn": true, "no": true, "nr": true, "nv": true, "ny": true,
+	"oc": true, "oj": true, "om": true, "or": true, "os": true,
+	"pa": true, "pi": true, "pl": true, "ps": true, "pt": true,
+	"qu": true,
+	"rm": true, "rn": true, "ro": true, "ru": true, "rw": true,
+	"sa": true, "sc": true, "sd": true, "se": true, "sg": true, "si": true, "sk": true, "sl": true, "sm": true, "sn": true, "so": true, "sq": true, "sr": true, "ss": true, "st": true, "su": true, "sv": true, "sw": true,
+	"ta": true, "te": true, "tg": true, "th": true, "ti": true, "tk": true, "tl": true, "tn": true, "to": true, "tr": true, "ts": true, "tt": true, "tw": true, "ty": true,
+	"ug": true, "uk": true, "ur": true, "uz": true,
+	"ve": true, "vi": true, "vo": true,
+	"wa": true, "wo": true,
+	"xh": true,
+	"yi": true, "yo": true,
+	"za": true, "zh": true, "zu": true,
+}
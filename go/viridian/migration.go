@@ -0,0 +1,74 @@
+package viridian
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+
+	"github.com/chaincode/viridian/go/viridian/barcode"
+)
+
+// Migration of assets stored by earlier versions of the chaincode
+//
+// The migration runs on every `init`, i.e. on instantiation and on upgrade.
+// It is idempotent, so running it again on migrated state changes nothing.
+
+// migrateProducts brings the GTINs of products stored before GTINs were
+// normalized and indexed in line with the current ones: a GTIN stored as
+// entered (e.g. 13 digits) is replaced by its GTIN-14, which the GTIN queries
+// look for, and the GTIN index entry that isGTINTaken looks up is added.
+// Other fields of the stored document are left as they are.
+func migrateProducts(stub shim.ChaincodeStubInterface) error {
+	resultsIterator, err := stub.GetStateByRange("product-", "product.")
+	if err != nil {
+		return err
+	}
+	keys := []string{}
+	products := []*Product{}
+	docs := []map[string]json.RawMessage{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return err
+		}
+		product := new(Product)
+		err = json.Unmarshal(queryResponse.Value, product)
+		if err != nil || product.DocType != "product" || len(product.GTIN) == 0 {
+			continue
+		}
+		var doc map[string]json.RawMessage
+		gtin, err := barcode.Normalize(product.GTIN)
+		if err == nil && gtin != product.GTIN {
+			product.GTIN = gtin
+			err = json.Unmarshal(queryResponse.Value, &doc)
+			if err != nil {
+				resultsIterator.Close()
+				return err
+			}
+			doc["gtin"], _ = json.Marshal(gtin)
+		}
+		keys = append(keys, queryResponse.Key)
+		products = append(products, product)
+		docs = append(docs, doc)
+	}
+	resultsIterator.Close()
+
+	for i, product := range products {
+		if docs[i] != nil {
+			err = putAsset(stub, keys[i], docs[i])
+			if err != nil {
+				return err
+			}
+		}
+		status := product.Status
+		if status == Rejected || status == Deleted || status == Outdated {
+			continue
+		}
+		err = putIndexEntries(stub, keys[i], product)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package money validates currencies (ISO 4217) and parses prices as written
+// on price tags into fixed-point amounts in the minor unit of their currency,
+// e.g. "4,99" euros into 499 cents.
+package money
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Amount is a price in the minor unit of its currency. Unlike the price
+// strings entered by users, amounts in the same currency can be compared.
+type Amount struct {
+	Value    int64  `json:"value"`    // in minor units, e.g. 499 for 4.99 EUR
+	Currency string `json:"currency"` // ISO 4217 code, e.g. "EUR"
+}
+
+// String returns the amount in major units with the currency code, e.g. "4.99 EUR"
+func (a Amount) String() string {
+	digits := MinorUnits(a.Currency)
+	if digits == 0 {
+		return fmt.Sprintf("%d %s", a.Value, a.Currency)
+	}
+	factor := pow10(digits)
+	return fmt.Sprintf("%d.%0*d %s", a.Value/factor, digits, a.Value%factor, a.Currency)
+}
+
+// Compare returns -1, 0 or 1 if a is less than, equal to or greater than b.
+// Amounts in different currencies cannot be compared.
+func (a Amount) Compare(b Amount) (int, error) {
+	if a.Currency != b.Currency {
+		return 0, fmt.Errorf("cannot compare %s with %s", a, b)
+	}
+	switch {
+	case a.Value < b.Value:
+		return -1, nil
+	case a.Value > b.Value:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// NormalizeCurrency returns the ISO 4217 code of a currency given by its
+// code (in any case) or a common symbol, e.g. "EUR" for "€" or "eur"
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.TrimSpace(currency)
+	if code, ok := symbols[currency]; ok {
+		return code, nil
+	}
+	code := strings.ToUpper(currency)
+	if _, ok := minorUnits[code]; !ok {
+		return "", fmt.Errorf("currency %q is neither an ISO 4217 code (e.g. EUR) nor a known symbol (e.g. €)", currency)
+	}
+	return code, nil
+}
+
+// MinorUnits returns the number of decimal places of the minor unit of the
+// currency, e.g. 2 for EUR (cents) and 0 for JPY, or -1 if the code is unknown
+func MinorUnits(code string) int {
+	digits, ok := minorUnits[code]
+	if !ok {
+		return -1
+	}
+	return digits
+}
+
+// Parse parses a price in the currency (code or symbol, see
+// NormalizeCurrency). Both "." and "," are accepted as decimal separator; if
+// both occur, the last one is the decimal separator and the other one groups
+// thousands, as do spaces and apostrophes ("1'234.50"). A single separator
+// followed by three digits groups thousands only for currencies without
+// minor unit ("1.000" JPY); for currencies with one or two decimal places,
+// "1.234" could be meant either way and is rejected as ambiguous. The Swiss
+// notation for whole amounts ("4.-" or "4.–") is accepted as well.
+func Parse(price string, currency string) (Amount, error) {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Amount{}, err
+	}
+	digits := minorUnits[code]
+
+	number := strings.TrimSpace(price)
+	for _, suffix := range []string{".-", ".–", ",-", ",–"} {
+		number = strings.TrimSuffix(number, suffix)
+	}
+	number = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "", "’", "").Replace(number)
+	if len(number) == 0 {
+		return Amount{}, fmt.Errorf("price %q is empty", price)
+	}
+
+	integer, fraction, err := split(number, digits)
+	if err != nil {
+		return Amount{}, fmt.Errorf("price %q is malformed: %s", price, err)
+	}
+	if len(fraction) > digits {
+		return Amount{}, fmt.Errorf("price %q has more than %d decimal places, which %s has", price, digits, code)
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+
+	var value int64
+	for _, digit := range integer + fraction {
+		if value > (math.MaxInt64-9)/10 {
+			return Amount{}, fmt.Errorf("price %q is too large", price)
+		}
+		value = value*10 + int64(digit-'0')
+	}
+	return Amount{value, code}, nil
+}
+
+// split splits a number without spaces into the digits before and after the
+// decimal separator, removing the separators grouping thousands
+func split(number string, digits int) (string, string, error) {
+	if !strings.ContainsAny(number, "0123456789") {
+		return "", "", fmt.Errorf("no digits")
+	}
+	integer, fraction := number, ""
+	if i := strings.LastIndexAny(number, ".,"); i >= 0 {
+		separator, head, tail := number[i:i+1], number[:i], number[i+1:]
+		switch {
+		case strings.Contains(head, separator):
+			// "1.234.567": the last separator groups thousands as well
+		case len(tail) == 3 && digits < 3 && !strings.ContainsAny(head, ".,") && isLeadingGroup(head):
+			if digits > 0 {
+				return "", "", fmt.Errorf("the %q is ambiguous, write thousands without separator (\"%s%s\") or with decimal places (\"%s%s%s\")",
+					separator, head, tail, number, map[string]string{".": ",", ",": "."}[separator], strings.Repeat("0", digits))
+			}
+			// "1.000" yen: a single separator grouping thousands
+		default:
+			integer, fraction = head, tail
+		}
+	}
+	if len(integer) == 0 {
+		integer = "0" // ".50"
+	}
+
+	if strings.ContainsAny(integer, ".,") {
+		if strings.Contains(integer, ".") && strings.Contains(integer, ",") {
+			return "", "", fmt.Errorf("thousands must be grouped with one separator")
+		}
+		groups := strings.FieldsFunc(integer, func(r rune) bool { return r == '.' || r == ',' })
+		if len(groups) != strings.Count(integer, ".")+strings.Count(integer, ",")+1 {
+			return "", "", fmt.Errorf("separators must be between digits")
+		}
+		for i, group := range groups {
+			if len(group) > 3 || i > 0 && len(group) < 3 || i == 0 && !isLeadingGroup(group) {
+				return "", "", fmt.Errorf("thousands separators must group three digits")
+			}
+		}
+		integer = strings.Join(groups, "")
+	}
+	for _, r := range integer + fraction {
+		if r < '0' || r > '9' {
+			return "", "", fmt.Errorf("%q is not a digit", r)
+		}
+	}
+	return integer, fraction, nil
+}
+
+// isLeadingGroup returns whether group can be the first group of digits of a
+// number whose thousands are grouped, i.e. one to three digits without
+// leading zero
+func isLeadingGroup(group string) bool {
+	return len(group) >= 1 && len(group) <= 3 && group[0] != '0'
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
@@ -2,10 +2,10 @@ package viridian
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
-	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
 	"github.com/hyperledger/fabric/protos/peer"
 )
 
@@ -13,28 +13,84 @@ import (
 type ProducerChaincode struct {
 }
 
+// ProducerLocaleData is the locale-specific (language-specific) part of a producer
+type ProducerLocaleData struct {
+	Lang        string   `json:"lang"` // ISO 639-1 language code, optionally with a BCP 47 region subtag, e.g. "de" or "de-CH" (see checkLocales), there should be only one locale data for each language
+	Name        string   `json:"name"`
+	Description string   `json:"description"` // optional
+	Address     string   `json:"address"`     // optional
+	LogoURLs    []string `json:"logoUrls"`    // https, http or ipfs URLs (see normalizeURLs) optional
+	URLs        []string `json:"urls"`        // https, http or ipfs URLs (see normalizeURLs) optional
+}
+
 // Producer is the asset associated with bringing a product to market, so being responsible for it
 type Producer struct {
 	ScorableAsset
-	DocType string   `json:"docType"` // docType is used to distinguish the various types of objects in state database
-	Name    string   `json:"name"`
-	Address string   `json:"address"` // optional
-	URL     string   `json:"url"`     // optional
-	Labels  []string `json:"labels"`
+	DocType string               `json:"docType"` // docType is used to distinguish the various types of objects in state database
+	Locales []ProducerLocaleData `json:"locales"`
+	Labels  []string             `json:"labels"`
+}
+
+// ex:
+// &Producer{
+//   ScorableAsset{...},
+//   DocType: "producer",
+//   Locales: []ProducerLocaleData{
+//     ProducerLocaleData{
+//       Lang: "de",
+//       Name: "Wander AG",
+//       Address: "CH-3176 Neuenegg, Switzerland",
+//       URLs: []string{"https://www.wander.ch/"},
+//     },
+//   },
+//   Labels: []string{},
+// }
+
+// UnmarshalJSON also reads producers stored before a producer could have
+// locales: "name", "address" and "url" become the only locale, whose lang is
+// unknown and therefore empty
+func (p *Producer) UnmarshalJSON(data []byte) error {
+	type producer Producer // without this method
+	var doc struct {
+		producer
+		Name    string `json:"name"`
+		Address string `json:"address"`
+		URL     string `json:"url"`
+	}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	*p = Producer(doc.producer)
+	if len(p.Locales) == 0 && len(doc.Name) > 0 {
+		locale := ProducerLocaleData{Name: doc.Name, Address: doc.Address}
+		if len(doc.URL) > 0 {
+			locale.URLs = []string{doc.URL}
+		}
+		p.Locales = []ProducerLocaleData{locale}
+	}
+	return nil
 }
 
 // InitProducer creates a new producer and adds it to the blockchain
 func (c *ProducerChaincode) InitProducer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// Arguments:
-	//  0                     1             2                              3                          4
-	// Key,                 Name,        Address,                         URL                       Labels
-	// "8a259c61-6825-...", "Wander AG", "CH-3176 Neuenegg, Switzerland", "https://www.wander.ch/", []
+	//  0                     1                                                                                                  2
+	// Key,                 Locales,                                                                                           Labels
+	// "8a259c61-6825-...", `[{"lang": "de", "name": "Wander AG", "address": "...", "urls": ["https://www.wander.ch/"]}]`, []
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3.")
+	}
+
 	var err error
-	createdBy, err := cid.GetID(stub)
+	createdBy, err := getRegisteredUser(stub)
 	if err != nil {
-		return shim.Error("Access denied. There is a problem with the client certificate.")
+		return shim.Error(err.Error())
+	}
+	createdAt, err := txTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	createdAt := time.Now()
 	updatedBy := ""
 	updatedAt, _ := time.Parse("2005-12-31", "1776-03-09")
 	supersedes := ""
@@ -42,34 +98,167 @@ func (c *ProducerChaincode) InitProducer(stub shim.ChaincodeStubInterface, args
 	changeReason := ""
 	score := Score{Environment: 0, Climate: 0, Society: 0, Health: 0, Economy: 0}
 
-	key := args[0]
-	name := args[1]
-	address := args[2]
-	url := args[3]
+	docType := "producer"
+	key, err := newAssetKey(stub, docType, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	var locales []ProducerLocaleData
+	err = json.Unmarshal([]byte(args[1]), &locales)
+	if err != nil {
+		return shim.Error("2nd argument 'locales' must be a string with " +
+			"a JSON list of objects with keys 'lang', 'name', 'description', 'address', " +
+			"'logoUrls', 'urls', where each contains a string, except 'logoUrls' and " +
+			"'urls' contain a list of strings.")
+	}
 	var labels []string
-	err = json.Unmarshal([]byte(args[4]), &labels)
+	err = json.Unmarshal([]byte(args[2]), &labels)
 	if err != nil {
-		return shim.Error("5th argument 'labels' must be a string with " +
+		return shim.Error("3rd argument 'labels' must be a string with " +
 			"a JSON list of label Keys labelling this producer: [\"label-bd80e824-938c-...\", \"label-127cc795-3a20-...\", ...]" +
 			"(or an empty list: [])")
 	}
 
-	docType := "producer"
 	producer := &Producer{
 		ScorableAsset{
 			UpdatableAsset{
 				ReviewableAsset{createdBy, createdAt, Preliminary},
 				updatedBy, updatedAt, supersedes, supersededBy, changeReason},
 			score},
-		docType, name, address, url, labels}
+		docType, locales, labels}
+
+	// ==== Check the locales ====
+	err = checkLocales(producer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Check the URLs ====
+	err = normalizeURLs(producer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Check that the labels exist ====
+	err = checkReferences(stub, producer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	jsonAsBytes, err := json.Marshal(producer)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	err = stub.PutState(docType+"-"+key, jsonAsBytes)
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = requestReview(stub, key, "", createdBy)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 	return shim.Success(nil)
 }
+
+// localeFields returns the lang and name of each locale of the producer
+func (p *Producer) localeFields() []localeFields {
+	fields := make([]localeFields, len(p.Locales))
+	for i, locale := range p.Locales {
+		fields[i] = localeFields{locale.Lang, locale.Name}
+	}
+	return fields
+}
+
+// urlFields returns the logo URLs and URLs of each locale of the producer
+func (p *Producer) urlFields() []urlField {
+	var fields []urlField
+	for i := range p.Locales {
+		locale := &p.Locales[i]
+		for j := range locale.LogoURLs {
+			fields = append(fields, urlField{fmt.Sprintf("locales[%d].logoUrls[%d]", i, j), &locale.LogoURLs[j]})
+		}
+		for j := range locale.URLs {
+			fields = append(fields, urlField{fmt.Sprintf("locales[%d].urls[%d]", i, j), &locale.URLs[j]})
+		}
+	}
+	return fields
+}
+
+// ReadProducer returns the producer stored under a key
+func (c *ProducerChaincode) ReadProducer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//  0
+	// Producer key
+	// "producer-84a234b7-c9d8-..."
+	return readAsset(stub, "producer", args)
+}
+
+// GetHistoryForProducer returns the history of all versions of a producer
+func (c *ProducerChaincode) GetHistoryForProducer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//  0
+	// Producer key
+	// "producer-84a234b7-c9d8-..."
+	return getHistory(stub, "producer", args)
+}
+
+// GetProducersByRangeWithPagination returns one page of the producers with
+// keys between a start key (inclusive) and an end key (exclusive)
+func (c *ProducerChaincode) GetProducersByRangeWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return getRangeWithPagination(stub, "producer", args)
+}
+
+// queryStringForName returns the rich query for the producers with a locale
+// matching the name, optionally only in the given language (see localeName).
+// Producers stored before they had locales are found by their "name" (see
+// Producer.UnmarshalJSON) as long as no language is given.
+func (c *ProducerChaincode) queryStringForName(name string, lang string, match string) (string, error) {
+	locale, err := localeName(name, lang, match)
+	if err != nil {
+		return "", err
+	}
+	s := newSelector("producer")
+	if len(lang) > 0 {
+		s.elemMatch("locales", locale)
+	} else {
+		oldShape, _ := selector{}.name(name, match)
+		s.eq("$or", []selector{selector{}.elemMatch("locales", locale), oldShape})
+	}
+	return s.queryStringWithIndex(indexDocTypeDoc, indexDocType)
+}
+
+// QueryProducersByName queries for producers that have a locale with the
+// given name, optionally only in the given language. By default, the name
+// must be equal, the match argument allows case-insensitive prefix or
+// substring search.
+func (c *ProducerChaincode) QueryProducersByName(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//      0                   1                            2
+	// "Wander AG"   lang: e.g. "de" (optional)   match: "EXACT", "PREFIX" or "SUBSTRING" (optional)
+	if len(args) < 1 || len(args) > 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 to 3")
+	}
+	queryString, err := c.queryStringForName(args[0], optionalArg(args, 1), optionalArg(args, 2))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryProducersByNameWithPagination is the paginated variant of QueryProducersByName
+func (c *ProducerChaincode) QueryProducersByNameWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//      0                  1                         2                          3     4
+	// "Wander AG",   lang: e.g. "de" or "",   match: e.g. "PREFIX" or "",   "20", bookmark ("" for the first page)
+	if len(args) != 5 {
+		return shim.Error("Incorrect number of arguments. Expecting 5")
+	}
+	queryString, err := c.queryStringForName(args[0], args[1], args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// references returns the keys of the labels of the producer
+func (p *Producer) references() []reference {
+	return listReferences("labels", "label", p.Labels)
+}
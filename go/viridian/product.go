@@ -2,12 +2,16 @@ package viridian
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
-	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
 	"github.com/hyperledger/fabric/protos/peer"
+
+	"github.com/chaincode/viridian/go/viridian/barcode"
+	"github.com/chaincode/viridian/go/viridian/money"
+	"github.com/chaincode/viridian/go/viridian/quantity"
 )
 
 // ProductChaincode is the chaincode associated with products
@@ -16,17 +20,19 @@ type ProductChaincode struct {
 
 // ProductLocaleData is the locale-specific (language-specific) part of a product
 type ProductLocaleData struct {
-	Lang        string   `json:"lang"`        // regex=/^[a-z]{2}$/ // ISO language code according to https://en.wikipedia.org/wiki/ISO_639-1, there should be only one locale data for each language
-	Name        string   `json:"name"`        // product 'short name'
-	Price       string   `json:"price"`       // optional
-	Currency    string   `json:"currency"`    // optional
-	Description string   `json:"description"` // optional
-	Quantities  []string `json:"quantities"`
-	Ingredients string   `json:"ingredients"` // optional
-	Packagings  []string `json:"packagings"`
-	Categories  []string `json:"categories"`
-	ImageURL    string   `json:"imageUrl"` // optional
-	URL         string   `json:"url"`      // optional
+	Lang                 string              `json:"lang"`                           // ISO 639-1 language code, optionally with a BCP 47 region subtag, e.g. "de" or "de-CH" (see checkLocales), there should be only one locale data for each language
+	Name                 string              `json:"name"`                           // product 'short name'
+	Price                string              `json:"price"`                          // optional // as entered, for display
+	Currency             string              `json:"currency"`                       // optional // ISO 4217 code, symbols like "€" are replaced by the code
+	PriceAmount          *money.Amount       `json:"priceAmount,omitempty"`          // set by the chaincode from price and currency, for comparing prices
+	Description          string              `json:"description"`                    // optional
+	Quantities           []string            `json:"quantities"`                     // as printed on the packaging, e.g. "6 x 330 ml"
+	NormalizedQuantities []quantity.Quantity `json:"normalizedQuantities,omitempty"` // set by the chaincode, one for each of the quantities
+	Ingredients          string              `json:"ingredients"`                    // optional
+	Packagings           []string            `json:"packagings"`
+	Categories           []string            `json:"categories"`
+	ImageURLs            []string            `json:"imageUrls"` // https, http or ipfs URLs (see normalizeURLs) optional
+	URLs                 []string            `json:"urls"`      // https, http or ipfs URLs (see normalizeURLs) optional
 }
 
 // ex:
@@ -34,23 +40,50 @@ type ProductLocaleData struct {
 //   Lang: "de",
 //   Name: "Ovomaltine crunchy cream — 400 g",
 //   Price: "4.99",
-//   Currency: "€",
+//   Currency: "EUR",
+//   PriceAmount: &money.Amount{Value: 499, Currency: "EUR"},
 //   Description: "Brotaufstrich mit malzhaltigem Getränkepulver Ovomaltine",
 //   Quantities: []string{"400 g"},
+//   NormalizedQuantities: []quantity.Quantity{{Value: 400, Unit: "g", Count: 1}},
 //   Ingredients: "33% malzhaltiges Getränkepulver: Ovomaltine (Gerstenmalzextrakt, kondensierte Magermilch, kondensiertes Milchserum, fettarmer Kakao, Zucker, Fruktose, Magnesiumcarbonat, Calciumphosphat, Rapsöl, Vitamine [A, E, B1, B2, Pantothensäure, B6, Folsäure, B12, C, Biotin, Niacin], Kochsalz, Aroma Vanillin), Zucker, Pflanzenöle (Raps- und Palmöl), 2.6% Haselnüsse, Calciumphosphat, fettarmer Kakao, Emulgator Sonnenblumenlecithin, Aroma Vanillin.",
 //   Packagings: []string{"Glas", "Plastik"},
 //   Categories: []string{"Brotaufstriche", "Frühstück", "Nougatcremes"},
-//   ImageURL: "ipfs://jf3f03-kf30-fk3-kf3-fk3.png",
-//   URL: "http://www.ovomaltine.de/produkte/ovomaltine-crunchy-cream-1/",
+//   ImageURLs: []string{"ipfs://QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o/ovomaltine-crunchy-cream.png"},
+//   URLs: []string{"http://www.ovomaltine.de/produkte/ovomaltine-crunchy-cream-1/"},
 // }
 
+// UnmarshalJSON also reads locales stored before a product could have
+// several image URLs and URLs: "imageUrl" and "url" become the only element
+// of "imageUrls" and "urls"
+func (l *ProductLocaleData) UnmarshalJSON(data []byte) error {
+	type localeData ProductLocaleData // without this method
+	var doc struct {
+		localeData
+		ImageURL string `json:"imageUrl"`
+		URL      string `json:"url"`
+	}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	*l = ProductLocaleData(doc.localeData)
+	if len(l.ImageURLs) == 0 && len(doc.ImageURL) > 0 {
+		l.ImageURLs = []string{doc.ImageURL}
+	}
+	if len(l.URLs) == 0 && len(doc.URL) > 0 {
+		l.URLs = []string{doc.URL}
+	}
+	return nil
+}
+
 // Product is the asset representing a product
 type Product struct {
 	ScorableAsset
-	DocType           string              `json:"docType"` // docType is used to distinguish the various types of objects in state database
-	GTIN              string              `json:"gtin"`    // optional
-	Producer          string              `json:"producer"`
+	DocType           string              `json:"docType"`   // docType is used to distinguish the various types of objects in state database
+	GTIN              string              `json:"gtin"`      // optional
+	Producers         []string            `json:"producers"` // several e.g. for co-packed and private-label products
 	ContainedProducts []string            `json:"containedProducts"`
+	ProductCategories []string            `json:"productCategories"` // only the key syntax is checked, as product categories are not stored by the chaincode yet
 	Labels            []string            `json:"labels"`
 	Locales           []ProductLocaleData `json:"locales"`
 }
@@ -80,45 +113,319 @@ type Product struct {
 //   },
 //   DocType: "product",
 //   GTIN: "7612100055557",
-//   Producer: "producer-afd05a40-4ed6-4ae5-8120-eb7daebc336c",
+//   Producers: []string{"producer-afd05a40-4ed6-4ae5-8120-eb7daebc336c"},
 //   ContainedProducts: []string{},
+//   ProductCategories: []string{},
 //   Labels: []string{"label-42c2f586-a893-485f-8995-8639446bb6b8"},
 //   Locale: []ProductLocaleData{
 //     &ProductLocaleData{
 //       Lang: "de",
 //       Name: "Ovomaltine crunchy cream — 400 g",
 //       Price: "4.99",
-//       Currency: "€",
+//       Currency: "EUR",
+//       PriceAmount: &money.Amount{Value: 499, Currency: "EUR"},
 //       Description: "Brotaufstrich mit malzhaltigem Getränkepulver Ovomaltine",
 //       Quantities: []string{"400 g"},
+//       NormalizedQuantities: []quantity.Quantity{{Value: 400, Unit: "g", Count: 1}},
 //       Ingredients: "33% malzhaltiges Getränkepulver: Ovomaltine (Gerstenmalzextrakt, kondensierte Magermilch, kondensiertes Milchserum, fettarmer Kakao, Zucker, Fruktose, Magnesiumcarbonat, Calciumphosphat, Rapsöl, Vitamine [A, E, B1, B2, Pantothensäure, B6, Folsäure, B12, C, Biotin, Niacin], Kochsalz, Aroma Vanillin), Zucker, Pflanzenöle (Raps- und Palmöl), 2.6% Haselnüsse, Calciumphosphat, fettarmer Kakao, Emulgator Sonnenblumenlecithin, Aroma Vanillin.",
 //       Packagings: []string{"Glas", "Plastik"},
 //       Categories: []string{"Brotaufstriche", "Frühstück", "Nougatcremes"},
-//       ImageURL: "ipfs://jf3f03-kf30-fk3-kf3-fk3.png",
-//       URL: "http://www.ovomaltine.de/produkte/ovomaltine-crunchy-cream-1/",
+//       ImageURLs: []string{"ipfs://QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o/ovomaltine-crunchy-cream.png"},
+//       URLs: []string{"http://www.ovomaltine.de/produkte/ovomaltine-crunchy-cream-1/"},
 //     },
 //   },
 // }
 
+// UnmarshalJSON also reads products stored before a product could have
+// several producers: "producer" becomes the only element of "producers"
+func (p *Product) UnmarshalJSON(data []byte) error {
+	type product Product // without this method
+	var doc struct {
+		product
+		Producer string `json:"producer"`
+	}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return err
+	}
+	*p = Product(doc.product)
+	if len(p.Producers) == 0 && len(doc.Producer) > 0 {
+		p.Producers = []string{doc.Producer}
+	}
+	return nil
+}
+
 // AddProduct creates a new product, stores it into chaincode state
 func (c *ProductChaincode) AddProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	// Arguments:
-	//  0                     1                  2                                  3            4                                   5
-	// Key,                 GTIN,            Producer,                     ContainedProducts, Labels,                             Locales
-	// "8a259c61-6825-...", "7612100055557", "producer-a3006838-bdf2-...", "[]",              `["label-31d3a05e-fb10-...", ...]`, `[{"lang": "de", ...}]`
+	//  0                     1                  2                                      3                  4                  5                                   6
+	// Key,                 GTIN,            Producers,                         ContainedProducts, ProductCategories, Labels,                             Locales
+	// "8a259c61-6825-...", "7612100055557", `["producer-a3006838-bdf2-...", ...]`, "[]",              "[]",              `["label-31d3a05e-fb10-...", ...]`, `[{"lang": "de", ...}]`
 	// or ""
-	if len(args) != 6 {
-		return shim.Error("Incorrect number of arguments. Expecting 6.")
+	if len(args) != 7 {
+		return shim.Error("Incorrect number of arguments. Expecting 7.")
+	}
+
+	fmt.Println("- start init product")
+	key, product, err := c.newProductFromArgs(stub, args)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
+	// ==== Check if product with this GTIN already exists ====
+	gtinTaken, err := c.isGTINTaken(stub, product.GTIN, "")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if gtinTaken {
+		return shim.Error("Product with this GTIN already exists!")
+	}
+
+	// ==== Check that the product does not contain itself ====
+	err = c.checkComposition(stub, key, product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Marshal product to JSON ====
+	jsonAsBytes, err := json.Marshal(product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	//Alternatively, build the product json string manually if you don'`t` want to use struct marshalling
+	//productJSONasString := `{"docType":"product",  "name": "` + productName + `", "color": "` + color + `", "size": ` + strconv.Itoa(size) + `, "owner": "` + owner + `"}`
+	//jsonAsBytes := []byte(str)
+
+	// === Save product to state ===
+	err = stub.PutState(key, jsonAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Appoint reviewers ====
+	err = requestReview(stub, key, "", product.CreatedBy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	//  ==== Index the product by GTIN ====
+	//  An 'index' is a normal key/value entry in state.
+	//  The key is a composite key, with the elements that you want to range query on listed first.
+	//  In our case, the composite key is based on indexName~gtin~key.
+	//  This enables the GTIN uniqueness check with a range query on indexName~gtin~*
+	err = putIndexEntries(stub, key, product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Product saved and indexed. Return success ====
+	fmt.Println("- end init product")
+	return shim.Success(nil)
+}
+
+// EditProduct creates a new version of an active product. The new version
+// is stored under a new key and supersedes the old version, which stays
+// active until the review of the new version is closed.
+func (c *ProductChaincode) EditProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0                              1                              2-8
+	// Old product key,              Change reason,                 same as for addProduct (new key, GTIN, producers, ...)
+	// "product-8a259c61-6825-...", "Wrong quantity information.", "3c6aa2a8-0a8b-...", "7612100055557", ...
+	if len(args) != 9 {
+		return shim.Error("Incorrect number of arguments. Expecting 9.")
+	}
+
+	fmt.Println("- start edit product")
+
+	// === Arg 0: Old key ===
+	oldKey := args[0]
+	if len(oldKey) == 0 {
+		return shim.Error("Old product key not provided")
+	}
+
+	// === Arg 1: Change reason ===
+	changeReason := args[1]
+	if len(changeReason) == 0 {
+		return shim.Error("Change reason not provided")
+	}
+
+	// ==== Check the old product ====
+	// Reading and writing the old product makes it part of the read-write set
+	// of this transaction. So if two edits of the same product are endorsed
+	// concurrently, only the first one is committed, the second one is
+	// invalidated by the MVCC check.
+	oldProduct, err := c.getProduct(stub, oldKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if oldProduct.Status != Active {
+		return shim.Error("Product " + oldKey + " is not active and cannot be edited")
+	}
+	if len(oldProduct.SupersededBy) > 0 {
+		return shim.Error("Product " + oldKey + " already has an edit or deletion pending: " + oldProduct.SupersededBy)
+	}
+
+	// === Args 2-8: New product ===
+	key, product, err := c.newProductFromArgs(stub, args[2:])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Check if another product with this GTIN already exists ====
+	gtinTaken, err := c.isGTINTaken(stub, product.GTIN, oldKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if gtinTaken {
+		return shim.Error("Product with this GTIN already exists!")
+	}
+
+	// ==== Link the two versions ====
+	// The new version keeps creation data and score of the old version,
+	// the editor is recorded as updater.
+	product.UpdatedBy = product.CreatedBy
+	product.UpdatedAt = product.CreatedAt
+	product.CreatedBy = oldProduct.CreatedBy
+	product.CreatedAt = oldProduct.CreatedAt
+	product.Score = oldProduct.Score
+	product.Supersedes = oldKey
+	product.ChangeReason = changeReason
+	oldProduct.SupersededBy = key
+
+	// ==== Check that the new version does not contain any version of itself ====
+	err = c.checkComposition(stub, key, product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// === Save both versions to state ===
+	err = putAsset(stub, key, product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAsset(stub, oldKey, oldProduct)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putIndexEntries(stub, key, product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Appoint reviewers ====
+	err = requestReview(stub, key, "", product.CreatedBy, product.UpdatedBy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fmt.Println("- end edit product")
+	return shim.Success(nil)
+}
+
+// DeleteProduct requests the deletion of an active product. The product stays
+// active until the review of the deletion request is closed.
+func (c *ProductChaincode) DeleteProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0                              1
+	// Product key,                  Change (i.e. deletion) reason
+	// "product-8a259c61-6825-...", "This product does not exist."
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+
+	fmt.Println("- start delete product")
+
+	updatedBy, err := getRegisteredUser(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// === Arg 0: Key ===
+	key := args[0]
+	if len(key) == 0 {
+		return shim.Error("Product key not provided")
+	}
+
+	// === Arg 1: Change reason ===
+	changeReason := args[1]
+	if len(changeReason) == 0 {
+		return shim.Error("Change reason not provided")
+	}
+
+	// ==== Check the product ====
+	product, err := c.getProduct(stub, key)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if product.Status != Active {
+		return shim.Error("Product " + key + " is not active and cannot be deleted")
+	}
+	if len(product.SupersededBy) > 0 {
+		return shim.Error("Product " + key + " already has an edit or deletion pending: " + product.SupersededBy)
+	}
+
+	// ==== Mark the product for deletion ====
+	// Requester and reason go to the reviews, the product keeps its last update until the deletion is approved
+	product.SupersededBy = DeletionRequest
+
+	err = putAsset(stub, key, product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// ==== Appoint reviewers ====
+	err = requestReview(stub, key, changeReason, product.CreatedBy, updatedBy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fmt.Println("- end delete product")
+	return shim.Success(nil)
+}
+
+// ReadProduct returns the product stored under a key
+func (c *ProductChaincode) ReadProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//  0
+	// Product key
+	// "product-8a259c61-6825-..."
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+	// Products stored in an older shape are returned in the current one
+	product, err := c.getProduct(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	jsonAsBytes, err := json.Marshal(product)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonAsBytes)
+}
+
+// GetHistoryForProduct returns the history of all versions of a product
+func (c *ProductChaincode) GetHistoryForProduct(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//  0
+	// Product key
+	// "product-8a259c61-6825-..."
+	return getHistory(stub, "product", args)
+}
+
+// newProductFromArgs performs the input sanitation of the arguments shared by
+// addProduct and editProduct and returns the new preliminary product together
+// with the key it shall be stored under
+func (c *ProductChaincode) newProductFromArgs(stub shim.ChaincodeStubInterface, args []string) (string, *Product, error) {
 	var err error
 
 	// Create initial values
-	createdBy, err := cid.GetID(stub)
+	createdBy, err := getRegisteredUser(stub)
 	if err != nil {
-		return shim.Error("Access denied. There is a problem with the client certificate.")
+		return "", nil, err
+	}
+	createdAt, err := txTime(stub)
+	if err != nil {
+		return "", nil, err
 	}
-	createdAt := time.Now()
 	updatedBy := ""
 	updatedAt, _ := time.Parse("2005-12-31", "1776-03-09")
 	supersedes := ""
@@ -127,126 +434,287 @@ func (c *ProductChaincode) AddProduct(stub shim.ChaincodeStubInterface, args []s
 	score := Score{Environment: 0, Climate: 0, Society: 0, Health: 0, Economy: 0}
 
 	// ==== Input sanitation ====
-	fmt.Println("- start init product")
 
 	// === Arg 0: Key ===
-	key := args[0]
-	if len(key) > 0 {
-		fmt.Println("Key: " + key)
-	} else {
-		fmt.Println("Key not provided")
+	docType := "product"
+	key, err := newAssetKey(stub, docType, args[0])
+	if err != nil {
+		return "", nil, err
 	}
+	fmt.Println("Key: " + key)
 
 	// === Arg 1: GTIN ===
+	// optional, because a product may not have a barcode
 	gtin := args[1]
 	if len(gtin) > 0 {
+		gtin, err = barcode.Normalize(gtin)
+		if err != nil {
+			return "", nil, errors.New("Invalid GTIN: " + err.Error())
+		}
 		fmt.Println("GTIN: " + gtin)
 	} else {
 		fmt.Println("GTIN not provided")
 	}
 
-	// === Arg 2: Producer ===
-	producer := args[2]
-	if len(producer) > 0 {
-		fmt.Println("Producer: " + gtin)
+	// === Arg 2: Producers ===
+	var producers []string
+	err = json.Unmarshal([]byte(args[2]), &producers)
+	if err != nil {
+		return "", nil, errors.New("'producers' must be a string with " +
+			"a JSON list of Keys of the producers, e.g.: [\"producer-a3006838-bdf2-...\", ...] " +
+			"(or an empty list: [])")
+	}
+	if len(producers) > 0 {
+		fmt.Printf("Producers: %v\n", producers)
 	} else {
-		fmt.Println("Producer not provided")
+		fmt.Println("Producers not provided")
 	}
 
 	// === Arg 3: ContainedProducts ===
 	var containedProducts []string
 	err = json.Unmarshal([]byte(args[3]), &containedProducts)
 	if err != nil {
-		return shim.Error("4th argument 'containedProducts' must be a string with " +
+		return "", nil, errors.New("'containedProducts' must be a string with " +
 			"a JSON list of Keys of contained products, e.g.: [\"product-123\", \"product-456\", ...] " +
 			"(or an empty list: [])")
 	}
 	if len(containedProducts) > 0 {
-		fmt.Printf("ContainedProducts: %v", containedProducts)
+		fmt.Printf("ContainedProducts: %v\n", containedProducts)
 	} else {
 		fmt.Println("ContainedProducts not provided")
 	}
 
-	// === Arg 4: Labels ===
+	// === Arg 4: ProductCategories ===
+	var productCategories []string
+	err = json.Unmarshal([]byte(args[4]), &productCategories)
+	if err != nil {
+		return "", nil, errors.New("'productCategories' must be a string with " +
+			"a JSON list of Keys of product categories, e.g.: [\"productCategory-123\", ...] " +
+			"(or an empty list: [])")
+	}
+	for i, productCategory := range productCategories {
+		if !isAssetKey("productCategory", productCategory) {
+			return "", nil, fmt.Errorf("'productCategories[%d]' %q is not a key of a product category, e.g. \"productCategory-123\"", i, productCategory)
+		}
+	}
+	if len(productCategories) > 0 {
+		fmt.Printf("ProductCategories: %v\n", productCategories)
+	} else {
+		fmt.Println("ProductCategories not provided")
+	}
+
+	// === Arg 5: Labels ===
 	var labels []string
-	err = json.Unmarshal([]byte(args[4]), &labels)
+	err = json.Unmarshal([]byte(args[5]), &labels)
 	if err != nil {
-		return shim.Error("5th argument 'labels' must be a string with " +
+		return "", nil, errors.New("'labels' must be a string with " +
 			"a JSON list of label Keys labelling this product: [\"label-bd80e824-938c-...\", \"label-127cc795-3a20-...\", ...]" +
 			"(or an empty list: [])")
 	}
 	if len(labels) > 0 {
-		fmt.Printf("Labels: %v", labels)
+		fmt.Printf("Labels: %v\n", labels)
 	} else {
 		fmt.Println("Labels not provided")
 	}
 
-	// === Arg 5: Locale ===
+	// === Arg 6: Locale ===
 	var locale []ProductLocaleData
-	err = json.Unmarshal([]byte(args[5]), &locale)
+	err = json.Unmarshal([]byte(args[6]), &locale)
 	if err != nil {
-		return shim.Error("6th argument 'locale' must be a string with " +
+		return "", nil, errors.New("'locale' must be a string with " +
 			"a JSON list of objects with keys 'lang', 'name', 'price', 'currency', " +
 			"'description', 'quantities', 'ingredients', 'packagings', 'categories', " +
-			"'imageUrl', 'url', where each contains a string, except 'quantities', " +
-			"'packagings' and 'categories' contain a list of strings.")
-	}
-	if len(locale) > 0 {
-		fmt.Printf("Locale: %+v", locale)
-	} else {
-		fmt.Println("Locale not provided")
-	}
-
-	// ==== Check if product with this GTIN already exists ====
-	queryResults, err := c.getQueryResultForGTIN(stub, gtin)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-	if len(queryResults) > 2 { // empty means only bytes 91 and 93 (beginning and end of msg) contained
-		return shim.Error("Product with this GTIN already exists!")
+			"'imageUrls', 'urls', where each contains a string, except 'quantities', " +
+			"'packagings', 'categories', 'imageUrls' and 'urls' contain a list of strings.")
 	}
+	fmt.Printf("Locale: %+v\n", locale)
 
-	// ==== Create product object and marshal to JSON ====
-	docType := "product"
+	// ==== Create product object ====
 	product := &Product{
 		ScorableAsset{
 			UpdatableAsset{
 				ReviewableAsset{createdBy, createdAt, Preliminary},
 				updatedBy, updatedAt, supersedes, supersededBy, changeReason},
 			score},
-		docType, gtin, producer, containedProducts, labels, locale}
-	jsonAsBytes, err := json.Marshal(product)
+		docType, gtin, producers, containedProducts, productCategories, labels, locale}
+
+	// ==== Check the locales ====
+	err = checkLocales(product)
 	if err != nil {
-		return shim.Error(err.Error())
+		return "", nil, err
+	}
+	err = product.normalizePrices()
+	if err != nil {
+		return "", nil, err
+	}
+	err = product.normalizeQuantities()
+	if err != nil {
+		return "", nil, err
+	}
+	err = normalizeURLs(product)
+	if err != nil {
+		return "", nil, err
 	}
-	//Alternatively, build the product json string manually if you don'`t` want to use struct marshalling
-	//productJSONasString := `{"docType":"product",  "name": "` + productName + `", "color": "` + color + `", "size": ` + strconv.Itoa(size) + `, "owner": "` + owner + `"}`
-	//jsonAsBytes := []byte(str)
 
-	// === Save product to state ===
-	err = stub.PutState(docType+"-"+key, jsonAsBytes)
+	// ==== Check that producers, contained products and labels exist ====
+	err = checkReferences(stub, product)
 	if err != nil {
-		return shim.Error(err.Error())
+		return "", nil, err
+	}
+	return key, product, nil
+}
+
+// getProduct reads the product stored under key from chaincode state
+func (c *ProductChaincode) getProduct(stub shim.ChaincodeStubInterface, key string) (*Product, error) {
+	jsonAsBytes, err := getAssetJSON(stub, key, "product")
+	if err != nil {
+		return nil, err
 	}
+	product := new(Product)
+	err = json.Unmarshal(jsonAsBytes, product)
+	if err != nil {
+		return nil, errors.New(key + " is not a valid product")
+	}
+	return product, nil
+}
 
-	// //  ==== Index the product to enable color-based range queries, e.g. return all blue products ====
-	// //  An 'index' is a normal key/value entry in state.
-	// //  The key is a composite key, with the elements that you want to range query on listed first.
-	// //  In our case, the composite key is based on indexName~color~name.
-	// //  This will enable very efficient state range queries based on composite keys matching indexName~color~*
-	// indexName := "color~name"
-	// colorNameIndexKey, err := stub.CreateCompositeKey(indexName, []string{product.Color, product.Name})
-	// if err != nil {
-	// 	return shim.Error(err.Error())
-	// }
-	// //  Save index entry to state. Only the key name is needed, no need to store a duplicate copy of the product.
-	// //  Note - passing a 'nil' value will effectively delete the key from state, therefore we pass null character as value
-	// value := []byte{0x00}
-	// stub.PutState(colorNameIndexKey, value)
+// gtinIndex is the composite key index of the products by GTIN. Entries are
+// removed when a product is rejected, deleted or outdated.
+const gtinIndex = "gtin~key"
 
-	// ==== Product saved and indexed. Return success ====
-	fmt.Println("- end init product")
-	return shim.Success(nil)
+// indexEntries returns the composite keys under which the product stored
+// under key is indexed
+func (p *Product) indexEntries(stub shim.ChaincodeStubInterface, key string) ([]string, error) {
+	if len(p.GTIN) == 0 {
+		return nil, nil
+	}
+	gtinIndexKey, err := stub.CreateCompositeKey(gtinIndex, []string{p.GTIN, key})
+	if err != nil {
+		return nil, err
+	}
+	return []string{gtinIndexKey}, nil
+}
+
+// localeFields returns lang and name of each locale of the product
+func (p *Product) localeFields() []localeFields {
+	fields := make([]localeFields, len(p.Locales))
+	for i, locale := range p.Locales {
+		fields[i] = localeFields{locale.Lang, locale.Name}
+	}
+	return fields
+}
+
+// normalizePrices replaces the currency of each locale by its ISO 4217 code
+// and sets the price amount. It returns a *LocaleError listing every price
+// and currency that cannot be parsed.
+func (p *Product) normalizePrices() error {
+	var invalid []InvalidField
+	for i := range p.Locales {
+		locale := &p.Locales[i]
+		field := fmt.Sprintf("locales[%d]", i)
+		locale.PriceAmount = nil
+		if len(locale.Currency) > 0 {
+			code, err := money.NormalizeCurrency(locale.Currency)
+			if err != nil {
+				invalid = append(invalid, InvalidField{field + ".currency", locale.Currency, "UNKNOWN_CURRENCY", ""})
+				continue
+			}
+			locale.Currency = code
+		}
+		if len(locale.Price) == 0 {
+			continue
+		}
+		if len(locale.Currency) == 0 {
+			invalid = append(invalid, InvalidField{field + ".currency", "", "MISSING", ""})
+			continue
+		}
+		amount, err := money.Parse(locale.Price, locale.Currency)
+		if err != nil {
+			invalid = append(invalid, InvalidField{field + ".price", locale.Price, "MALFORMED", err.Error()})
+			continue
+		}
+		locale.PriceAmount = &amount
+	}
+	if len(invalid) > 0 {
+		return &LocaleError{invalid}
+	}
+	return nil
+}
+
+// normalizeQuantities sets the normalized quantities of each locale. It
+// returns a *LocaleError listing every quantity that cannot be parsed.
+func (p *Product) normalizeQuantities() error {
+	var invalid []InvalidField
+	for i := range p.Locales {
+		locale := &p.Locales[i]
+		locale.NormalizedQuantities = nil
+		for j, raw := range locale.Quantities {
+			q, err := quantity.Parse(raw)
+			if err != nil {
+				invalid = append(invalid, InvalidField{fmt.Sprintf("locales[%d].quantities[%d]", i, j), raw, "MALFORMED", err.Error()})
+				continue
+			}
+			locale.NormalizedQuantities = append(locale.NormalizedQuantities, q)
+		}
+	}
+	if len(invalid) > 0 {
+		return &LocaleError{invalid}
+	}
+	return nil
+}
+
+// urlFields returns the image URLs and URLs of each locale of the product
+func (p *Product) urlFields() []urlField {
+	var fields []urlField
+	for i := range p.Locales {
+		locale := &p.Locales[i]
+		for j := range locale.ImageURLs {
+			fields = append(fields, urlField{fmt.Sprintf("locales[%d].imageUrls[%d]", i, j), &locale.ImageURLs[j]})
+		}
+		for j := range locale.URLs {
+			fields = append(fields, urlField{fmt.Sprintf("locales[%d].urls[%d]", i, j), &locale.URLs[j]})
+		}
+	}
+	return fields
+}
+
+// references returns the keys of the producers, contained products and labels
+// of the product
+func (p *Product) references() []reference {
+	refs := listReferences("producers", "producer", p.Producers)
+	refs = append(refs, listReferences("containedProducts", "product", p.ContainedProducts)...)
+	return append(refs, listReferences("labels", "label", p.Labels)...)
+}
+
+// isGTINTaken checks if a product other than the one stored under ignoreKey
+// (e.g. the version that is being edited) already uses the GTIN.
+// Unlike a rich query, the range query on the GTIN index is re-executed by the
+// MVCC check at commit time, so two concurrent transactions cannot both
+// register the same GTIN. It also works on LevelDB.
+func (c *ProductChaincode) isGTINTaken(stub shim.ChaincodeStubInterface, gtin string, ignoreKey string) (bool, error) {
+	if len(gtin) == 0 { // products without GTIN cannot collide
+		return false, nil
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(gtinIndex, []string{gtin})
+	if err != nil {
+		return false, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		indexEntry, err := resultsIterator.Next()
+		if err != nil {
+			return false, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(indexEntry.Key)
+		if err != nil {
+			return false, err
+		}
+		if attributes[1] != ignoreKey {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // =======Rich queries =========================================================================
@@ -262,14 +730,25 @@ func (c *ProductChaincode) AddProduct(stub shim.ChaincodeStubInterface, args []s
 // Rich queries can be used for point-in-time queries against a peer.
 // ============================================================================================
 
-func (c *ProductChaincode) getQueryResultForGTIN(stub shim.ChaincodeStubInterface, gtin string) ([]byte, error) {
-	queryString := fmt.Sprintf("{\"selector\": {\"docType\": \"product\", \"gtin\": \"%s\"}}", gtin)
-	return getQueryResultForQueryString(stub, queryString)
+// queryStringForGTIN returns the rich query for the products with a (normalized) GTIN
+func (c *ProductChaincode) queryStringForGTIN(gtin string) (string, error) {
+	return newSelector("product").eq("gtin", gtin).queryStringWithIndex(indexProductGTINDoc, indexProductGTIN)
+}
+
+// queryStringForName returns the rich query for the products with a locale
+// matching the name, optionally only in the given language (see localeName)
+func (c *ProductChaincode) queryStringForName(name string, lang string, match string) (string, error) {
+	locale, err := localeName(name, lang, match)
+	if err != nil {
+		return "", err
+	}
+	return newSelector("product").elemMatch("locales", locale).queryStringWithIndex(indexDocTypeDoc, indexDocType)
 }
 
 // QueryProductsByGTIN queries for products based on a passed in GTIN number (barcode).
 // This is an example of a parameterized query where the query logic is baked into the chaincode,
-// and accepting a single query parameter (GTIN).
+// and accepting a single query parameter (GTIN). The GTIN is normalized to
+// GTIN-14 like the stored ones, so all zero-padded forms of a barcode match.
 // Only available on state databases that support rich query (e.g. CouchDB)
 func (c *ProductChaincode) QueryProductsByGTIN(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	//       0
@@ -277,34 +756,214 @@ func (c *ProductChaincode) QueryProductsByGTIN(stub shim.ChaincodeStubInterface,
 	if len(args) < 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
-	gtin := args[0]
-	queryResults, err := c.getQueryResultForGTIN(stub, gtin)
+	gtin, err := barcode.Normalize(args[0])
+	if err != nil {
+		return shim.Error("Invalid GTIN: " + err.Error())
+	}
+	queryString, err := c.queryStringForGTIN(gtin)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	return shim.Success(queryResults)
+	return queryResponse(stub, queryString, args, false)
 }
 
-// QueryProductsByName queries for products based on name
+// QueryProductsByGTINWithPagination is the paginated variant of QueryProductsByGTIN
+func (c *ProductChaincode) QueryProductsByGTINWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//       0              1     2
+	// "7612100055557",  "20", bookmark ("" for the first page)
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	gtin, err := barcode.Normalize(args[0])
+	if err != nil {
+		return shim.Error("Invalid GTIN: " + err.Error())
+	}
+	queryString, err := c.queryStringForGTIN(gtin)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// QueryProductsByName queries for products based on name. By default, the
+// name must be equal, the match argument allows case-insensitive prefix or
+// substring search.
 func (c *ProductChaincode) QueryProductsByName(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	//       0                                1
-	// product name query string   lang: e.g. "de" (optional)
-	if len(args) < 1 {
-		return shim.Error("Incorrect number of arguments. Expecting at least 1")
-	}
-	name := args[0]
-	var queryString string
-	if len(args) > 1 {
-		lang := args[1]
-		if len(lang) > 0 {
-			queryString = fmt.Sprintf("{\"selector\": {\"docType\": \"product\", \"locales\": [{\"lang\": \"%s\", \"name\": \"%s\"}]}}", lang, name)
-		}
-	} else {
-		queryString = fmt.Sprintf("{\"selector\": {\"docType\": \"product\", \"locales\": [{\"name\": \"%s\"}]}}", name)
+	//       0                                1                            2
+	// product name query string   lang: e.g. "de" (optional)   match: "EXACT", "PREFIX" or "SUBSTRING" (optional)
+	if len(args) < 1 || len(args) > 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 to 3")
+	}
+	queryString, err := c.queryStringForName(args[0], optionalArg(args, 1), optionalArg(args, 2))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryProductsByNameWithPagination is the paginated variant of QueryProductsByName
+func (c *ProductChaincode) QueryProductsByNameWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//       0                                1                  2                                3     4
+	// product name query string   lang: e.g. "de" or "",     match: e.g. "PREFIX" or "",    "20", bookmark ("" for the first page)
+	if len(args) != 5 {
+		return shim.Error("Incorrect number of arguments. Expecting 5")
+	}
+	queryString, err := c.queryStringForName(args[0], args[1], args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// queryStringForProducer returns the rich query for the products of a
+// producer, including the products stored with a single "producer" (see
+// Product.UnmarshalJSON)
+func (c *ProductChaincode) queryStringForProducer(producer string) (string, error) {
+	return newSelector("product").eq("$or", []selector{
+		selector{}.includes("producers", producer),
+		selector{}.eq("producer", producer),
+	}).queryStringWithIndex(indexDocTypeDoc, indexDocType)
+}
+
+// QueryProductsByProducer queries for the products of the producer with the given key
+func (c *ProductChaincode) QueryProductsByProducer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//                      0
+	// "producer-84a234b7-c9d8-43b2-93c9-90f83d8773fb"
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	queryString, err := c.queryStringForProducer(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryProductsByProducerWithPagination is the paginated variant of QueryProductsByProducer
+func (c *ProductChaincode) QueryProductsByProducerWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//          0             1     2
+	// "producer-84a2...",  "20", bookmark ("" for the first page)
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	queryString, err := c.queryStringForProducer(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// queryStringForStatus returns the rich query for the products with a status,
+// e.g. the review queue with status PRELIMINARY
+func (c *ProductChaincode) queryStringForStatus(statusName string) (string, error) {
+	status, ok := statusNames[statusName]
+	if !ok {
+		return "", errors.New("'status' must be one of \"PRELIMINARY\", \"ACTIVE\", \"OUTDATED\", \"DELETED\" or \"REJECTED\"")
+	}
+	return newSelector("product").eq("status", status).queryStringWithIndex(indexStatusDoc, indexStatus)
+}
+
+// QueryProductsByStatus queries for the products with a status
+func (c *ProductChaincode) QueryProductsByStatus(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//     0
+	// "ACTIVE"
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	queryString, err := c.queryStringForStatus(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryProductsByStatusWithPagination is the paginated variant of QueryProductsByStatus
+func (c *ProductChaincode) QueryProductsByStatusWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//     0       1     2
+	// "ACTIVE", "20", bookmark ("" for the first page)
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	queryString, err := c.queryStringForStatus(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// queryStringForLabel returns the rich query for the products with a label
+func (c *ProductChaincode) queryStringForLabel(label string) (string, error) {
+	return newSelector("product").includes("labels", label).queryStringWithIndex(indexDocTypeDoc, indexDocType)
+}
+
+// QueryProductsByLabel queries for the products with the label with the given key
+func (c *ProductChaincode) QueryProductsByLabel(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//                      0
+	// "label-31d3a05e-fb10-483c-8c8b-0c7079e5bc95"
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	queryString, err := c.queryStringForLabel(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	queryResults, err := getQueryResultForQueryString(stub, queryString)
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryProductsByLabelWithPagination is the paginated variant of QueryProductsByLabel
+func (c *ProductChaincode) QueryProductsByLabelWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//        0            1     2
+	// "label-31d3...",  "20", bookmark ("" for the first page)
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+	queryString, err := c.queryStringForLabel(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// queryStringForCategory returns the rich query for the products with a
+// locale having the category, optionally only in the given language
+func (c *ProductChaincode) queryStringForCategory(category string, lang string) (string, error) {
+	locale := selector{}.includes("categories", category)
+	if len(lang) > 0 {
+		locale.eq("lang", lang)
+	}
+	return newSelector("product").elemMatch("locales", locale).queryStringWithIndex(indexDocTypeDoc, indexDocType)
+}
+
+// QueryProductsByCategory queries for the products in a category
+func (c *ProductChaincode) QueryProductsByCategory(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//         0                        1
+	// "Brotaufstrich",   lang: e.g. "de" (optional)
+	if len(args) < 1 || len(args) > 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2")
+	}
+	queryString, err := c.queryStringForCategory(args[0], optionalArg(args, 1))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryProductsByCategoryWithPagination is the paginated variant of QueryProductsByCategory
+func (c *ProductChaincode) QueryProductsByCategoryWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//         0                 1           2     3
+	// "Brotaufstrich",   "de" or "",     "20", bookmark ("" for the first page)
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4")
+	}
+	queryString, err := c.queryStringForCategory(args[0], args[1])
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	return shim.Success(queryResults)
+	return queryResponse(stub, queryString, args, true)
+}
+
+// GetProductsByRangeWithPagination returns one page of the products with
+// keys between a start key (inclusive) and an end key (exclusive)
+func (c *ProductChaincode) GetProductsByRangeWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return getRangeWithPagination(stub, "product", args)
 }
@@ -0,0 +1,129 @@
+// Package quantity parses the quantities printed on product packagings, e.g.
+// "400 g", "1,5 l", "12 fl oz", "6 x 330 ml" or "Abtropfgewicht 240 g", into
+// a canonical form, so that pack sizes can be compared and prices per
+// kilogram or liter computed.
+package quantity
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Canonical units
+const (
+	Gram       = "g"   // mass
+	Milliliter = "ml"  // volume
+	Piece      = "pcs" // number of pieces, e.g. of tea bags
+)
+
+// Quantity is the canonical form of a quantity
+type Quantity struct {
+	Value   float64 `json:"value"`             // of one item, in the unit, e.g. 330
+	Unit    string  `json:"unit"`              // Gram, Milliliter or Piece
+	Count   int     `json:"count"`             // number of items, e.g. 6 for "6 x 330 ml", otherwise 1
+	Drained bool    `json:"drained,omitempty"` // whether the value is the drained weight
+}
+
+// Total returns the quantity of all items, e.g. 1980 for "6 x 330 ml"
+func (q Quantity) Total() float64 {
+	return q.Value * float64(q.Count)
+}
+
+// unit is a unit of measure that can be converted to a canonical unit
+type unit struct {
+	canonical string
+	factor    float64 // canonical units per unit
+}
+
+// units maps the (lowercase) units and their common spellings to the
+// canonical units. Fluid ounces, pints, quarts and gallons differ between US
+// customary and imperial units (a US pint is 473.18 ml, an imperial one
+// 568.26 ml). Without prefix, they are the US customary ones, which US
+// packagings print; imperial ones need the prefix "imp", e.g. "1 imp pt".
+var units = map[string]unit{
+	"mg": {Gram, 0.001}, "g": {Gram, 1}, "gr": {Gram, 1}, "kg": {Gram, 1000},
+	"oz": {Gram, 28.349523125}, "lb": {Gram, 453.59237}, "lbs": {Gram, 453.59237},
+
+	"ml": {Milliliter, 1}, "cl": {Milliliter, 10}, "dl": {Milliliter, 100}, "l": {Milliliter, 1000}, "ltr": {Milliliter, 1000},
+	"fl oz": {Milliliter, 29.5735295625}, "fl. oz": {Milliliter, 29.5735295625}, "fl.oz": {Milliliter, 29.5735295625},
+	"pt": {Milliliter, 473.176473}, "qt": {Milliliter, 946.352946}, "gal": {Milliliter, 3785.411784},
+	"us fl oz": {Milliliter, 29.5735295625}, "us pt": {Milliliter, 473.176473}, "us qt": {Milliliter, 946.352946}, "us gal": {Milliliter, 3785.411784},
+	"imp fl oz": {Milliliter, 28.4130625}, "imp pt": {Milliliter, 568.26125}, "imp qt": {Milliliter, 1136.5225}, "imp gal": {Milliliter, 4546.09},
+
+	"pcs": {Piece, 1}, "pc": {Piece, 1}, "pieces": {Piece, 1}, "piece": {Piece, 1},
+	"stück": {Piece, 1}, "stk": {Piece, 1}, "stk.": {Piece, 1}, "pièces": {Piece, 1}, "pezzi": {Piece, 1},
+}
+
+// drainedKeywords mark a drained weight, before or after the quantity
+var drainedKeywords = []string{
+	"drained weight", "drained", "abtropfgewicht", "poids net égoutté", "poids égoutté", "égoutté", "peso sgocciolato", "sgocciolato",
+}
+
+// quantityPattern matches an optional count, a number and a unit
+var quantityPattern = regexp.MustCompile(`^(?:(\d+)\s*[x×*]\s*)?(\d+(?:[.,]\d+)?)\s*(\D.*)$`)
+
+// Parse parses a quantity: a number with a unit, optionally preceded by the
+// number of items of a multipack ("6 x 330 ml") and marked as drained weight
+// ("Abtropfgewicht 240 g" or "240 g drained"). The value is rounded to three
+// decimal places of the canonical unit.
+func Parse(s string) (Quantity, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	text = strings.TrimSpace(strings.TrimSuffix(text, "℮")) // estimated sign
+
+	drained := false
+	for _, keyword := range drainedKeywords {
+		if strings.HasPrefix(text, keyword) {
+			text, drained = strings.TrimLeft(text[len(keyword):], ": "), true
+			break
+		}
+		if strings.HasSuffix(text, keyword) {
+			text, drained = strings.TrimRight(text[:len(text)-len(keyword)], ", "), true
+			break
+		}
+	}
+
+	match := quantityPattern.FindStringSubmatch(text)
+	if match == nil {
+		return Quantity{}, fmt.Errorf("quantity %q must be a number with a unit, e.g. \"400 g\", or a multipack, e.g. \"6 x 330 ml\"", s)
+	}
+	count := 1
+	if len(match[1]) > 0 {
+		var err error
+		count, err = strconv.Atoi(match[1])
+		if err != nil {
+			return Quantity{}, fmt.Errorf("quantity %q has an invalid number of items: %s", s, err)
+		}
+		if count < 1 {
+			return Quantity{}, fmt.Errorf("quantity %q must have at least one item", s)
+		}
+	}
+	value, err := strconv.ParseFloat(strings.Replace(match[2], ",", ".", 1), 64)
+	if err != nil {
+		return Quantity{}, fmt.Errorf("quantity %q has an invalid number: %s", s, err)
+	}
+	if value <= 0 {
+		return Quantity{}, fmt.Errorf("quantity %q must be greater than zero", s)
+	}
+	u, ok := units[strings.TrimSpace(match[3])]
+	if !ok {
+		return Quantity{}, fmt.Errorf("quantity %q has the unknown unit %q, expected one of %s", s, strings.TrimSpace(match[3]), knownUnits())
+	}
+	if drained && u.canonical != Gram {
+		return Quantity{}, fmt.Errorf("quantity %q is a drained weight, but %q is not a unit of mass", s, strings.TrimSpace(match[3]))
+	}
+	return Quantity{math.Round(value*u.factor*1000) / 1000, u.canonical, count, drained}, nil
+}
+
+// knownUnits lists the units Parse accepts
+func knownUnits() string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -2,9 +2,14 @@ package viridian
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
 )
 
 // General helper functions for queries
@@ -34,8 +39,12 @@ func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorI
 		buffer.WriteString("\"")
 
 		buffer.WriteString(", \"Value\":")
-		// Record is a JSON object, so we write as-is
-		buffer.WriteString(string(queryResponse.Value))
+		// Record is a JSON object, so we write as-is (in the current shape)
+		value, err := currentShape(queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(value)
 		buffer.WriteString("}")
 		bArrayMemberAlreadyWritten = true
 	}
@@ -67,3 +76,212 @@ func getQueryResultForQueryString(stub shim.ChaincodeStubInterface, queryString
 
 	return buffer.Bytes(), nil
 }
+
+// QueryPage is the response envelope of the paginated queries. The bookmark
+// is passed to the next call to get the next page.
+type QueryPage struct {
+	Records             []QueryRecord `json:"records"`
+	FetchedRecordsCount int32         `json:"fetchedRecordsCount"`
+	Bookmark            string        `json:"bookmark"`
+}
+
+// QueryRecord is one asset in a query result
+type QueryRecord struct {
+	Key   string          `json:"Key"`
+	Value json.RawMessage `json:"Value"`
+}
+
+// =========================================================================================
+// parsePaginationArgs parses the page size and bookmark, which are the last
+// two arguments of all paginated queries
+// =========================================================================================
+func parsePaginationArgs(args []string) (int32, string, error) {
+	pageSize, err := strconv.ParseInt(args[len(args)-2], 10, 32)
+	if err != nil || pageSize < 1 {
+		return 0, "", errors.New("'pageSize' must be a positive number")
+	}
+	return int32(pageSize), args[len(args)-1], nil
+}
+
+// optionalArg returns the i-th argument, or "" if it was not passed
+func optionalArg(args []string, i int) string {
+	if len(args) > i {
+		return args[i]
+	}
+	return ""
+}
+
+// =========================================================================================
+// getQueryResultForQueryStringWithPagination executes the passed in query string
+// and returns one page of the result set as a QueryPage
+// =========================================================================================
+func getQueryResultForQueryStringWithPagination(stub shim.ChaincodeStubInterface, queryString string,
+	pageSize int32, bookmark string) ([]byte, error) {
+
+	fmt.Printf("- getQueryResultForQueryStringWithPagination queryString:\n%s\n", queryString)
+
+	resultsIterator, responseMetadata, err := stub.GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructQueryPage(resultsIterator, responseMetadata)
+}
+
+// =========================================================================================
+// queryResponse executes the passed in query string and returns all results or,
+// if paginated, one page of them. The page size and the bookmark are the last
+// two arguments of paginated queries.
+// =========================================================================================
+func queryResponse(stub shim.ChaincodeStubInterface, queryString string, args []string, paginated bool) peer.Response {
+	var queryResults []byte
+	var err error
+	if paginated {
+		var pageSize int32
+		var bookmark string
+		pageSize, bookmark, err = parsePaginationArgs(args)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		queryResults, err = getQueryResultForQueryStringWithPagination(stub, queryString, pageSize, bookmark)
+	} else {
+		queryResults, err = getQueryResultForQueryString(stub, queryString)
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(queryResults)
+}
+
+// =========================================================================================
+// getRangeWithPagination returns one page of the assets of the given docType
+// with keys in the range [startKey, endKey). Empty keys stand for the first and
+// the last asset of the docType.
+// =========================================================================================
+func getRangeWithPagination(stub shim.ChaincodeStubInterface, docType string, args []string) peer.Response {
+	//  0                             1                             2           3
+	// Start key,                   End key,                      Page size,  Bookmark
+	// "product-1fcc2c43-12a1-...", "product-8a259c61-6825-...", "20",       ""
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4.")
+	}
+	prefix := docType + "-"
+	startKey, endKey := args[0], args[1]
+	if (len(startKey) > 0 && !strings.HasPrefix(startKey, prefix)) || (len(endKey) > 0 && !strings.HasPrefix(endKey, prefix)) {
+		return shim.Error("Start and end key must be keys of " + docType + "s")
+	}
+	if len(startKey) == 0 {
+		startKey = prefix
+	}
+	if len(endKey) == 0 {
+		endKey = docType + "." // '.' is the character after '-'
+	}
+	pageSize, bookmark, err := parsePaginationArgs(args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	resultsIterator, responseMetadata, err := stub.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+
+	jsonAsBytes, err := constructQueryPage(resultsIterator, responseMetadata)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonAsBytes)
+}
+
+// constructQueryPage marshals the results and the metadata of a paginated query
+func constructQueryPage(resultsIterator shim.StateQueryIteratorInterface, responseMetadata *peer.QueryResponseMetadata) ([]byte, error) {
+	page := QueryPage{Records: []QueryRecord{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		value, err := currentShape(queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		page.Records = append(page.Records, QueryRecord{queryResponse.Key, json.RawMessage(value)})
+	}
+	if responseMetadata != nil {
+		page.FetchedRecordsCount = responseMetadata.FetchedRecordsCount
+		page.Bookmark = responseMetadata.Bookmark
+	}
+	return json.Marshal(page)
+}
+
+// currentShape returns the stored JSON of an asset in the shape it is written
+// in now: products and producers stored in an older shape (see
+// Product.UnmarshalJSON and Producer.UnmarshalJSON) are converted, all other
+// values are returned as stored
+func currentShape(value []byte) ([]byte, error) {
+	var doc struct {
+		DocType string `json:"docType"`
+	}
+	if json.Unmarshal(value, &doc) != nil {
+		return value, nil
+	}
+	var asset interface{}
+	switch doc.DocType {
+	case "product":
+		asset = new(Product)
+	case "producer":
+		asset = new(Producer)
+	default:
+		return value, nil
+	}
+	err := json.Unmarshal(value, asset)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(asset)
+}
+
+// =========================================================================================
+// readAsset returns the stored JSON of the asset under the key passed as the
+// only argument (in the current shape), if it is of the given docType
+// =========================================================================================
+func readAsset(stub shim.ChaincodeStubInterface, docType string, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+	jsonAsBytes, err := getAssetJSON(stub, args[0], docType)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	jsonAsBytes, err = currentShape(jsonAsBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(jsonAsBytes)
+}
+
+// getAssetJSON reads the asset stored under key from chaincode state and
+// checks that it has the given docType
+func getAssetJSON(stub shim.ChaincodeStubInterface, key string, docType string) ([]byte, error) {
+	name := strings.ToUpper(docType[:1]) + docType[1:]
+	if len(key) == 0 {
+		return nil, errors.New(name + " key not provided")
+	}
+	jsonAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.New("Failed to get " + docType + ": " + err.Error())
+	}
+	if jsonAsBytes == nil {
+		return nil, errors.New(name + " " + key + " does not exist")
+	}
+	var doc struct {
+		DocType string `json:"docType"`
+	}
+	err = json.Unmarshal(jsonAsBytes, &doc)
+	if err != nil || doc.DocType != docType {
+		return nil, errors.New(key + " is not a " + docType)
+	}
+	return jsonAsBytes, nil
+}
@@ -0,0 +1,99 @@
+package viridian
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Referential integrity
+//
+// Assets reference other assets by their key, e.g. a product references its
+// producers, its labels and the products it contains. Before an asset is
+// written, all its references are checked: the referenced asset must exist,
+// have the expected docType and must not be Deleted or Rejected.
+
+// reference is a key stored in one field of an asset, pointing to another asset
+type reference struct {
+	Field   string `json:"field"`   // e.g. "producers[0]" or "labels[1]"
+	Key     string `json:"key"`     // e.g. "producer-a3006838-bdf2-..."
+	DocType string `json:"docType"` // docType the referenced asset must have
+}
+
+// DanglingReference is a reference that does not point to a usable asset
+type DanglingReference struct {
+	Field   string `json:"field"`
+	Key     string `json:"key"`
+	DocType string `json:"docType"`
+	Problem string `json:"problem"` // NOT_FOUND, WRONG_DOCTYPE, DELETED or REJECTED
+}
+
+// ReferenceError lists all dangling references of an asset
+type ReferenceError struct {
+	Dangling []DanglingReference `json:"danglingReferences"`
+}
+
+func (e *ReferenceError) Error() string {
+	jsonAsBytes, _ := json.Marshal(e)
+	return fmt.Sprintf("%d dangling reference(s): %s", len(e.Dangling), jsonAsBytes)
+}
+
+// referencing is implemented by assets that reference other assets
+type referencing interface {
+	references() []reference
+}
+
+// listReferences returns one reference of docType for each key in keys
+func listReferences(field string, docType string, keys []string) []reference {
+	refs := make([]reference, 0, len(keys))
+	for i, key := range keys {
+		refs = append(refs, reference{fmt.Sprintf("%s[%d]", field, i), key, docType})
+	}
+	return refs
+}
+
+// checkReferences reads all assets referenced by asset and returns a
+// *ReferenceError listing every reference that does not point to an existing,
+// non-deleted and non-rejected asset of the expected docType
+func checkReferences(stub shim.ChaincodeStubInterface, asset referencing) error {
+	var dangling []DanglingReference
+	for _, ref := range asset.references() {
+		problem, err := referenceProblem(stub, ref)
+		if err != nil {
+			return err
+		}
+		if len(problem) > 0 {
+			dangling = append(dangling, DanglingReference{ref.Field, ref.Key, ref.DocType, problem})
+		}
+	}
+	if len(dangling) > 0 {
+		return &ReferenceError{dangling}
+	}
+	return nil
+}
+
+// referenceProblem returns why ref is dangling, or "" if it is fine
+func referenceProblem(stub shim.ChaincodeStubInterface, ref reference) (string, error) {
+	jsonAsBytes, err := stub.GetState(ref.Key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get %s: %s", ref.Key, err.Error())
+	}
+	if jsonAsBytes == nil {
+		return "NOT_FOUND", nil
+	}
+	var doc struct {
+		DocType string `json:"docType"`
+		Status  Status `json:"status"`
+	}
+	if json.Unmarshal(jsonAsBytes, &doc) != nil || doc.DocType != ref.DocType {
+		return "WRONG_DOCTYPE", nil
+	}
+	switch doc.Status {
+	case Deleted:
+		return "DELETED", nil
+	case Rejected:
+		return "REJECTED", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,356 @@
+package viridian
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+/**
+  Reviews, i.e. peer reviews, decide about whether an asset (information, product,
+  producer, label) is of high enough quality and unbiased enough to go online.
+  The system selects random users and appoints them to perform a review. For
+  example, five users could be selected of which at least three must approve
+  the asset.
+**/
+
+// ReviewChaincode is the chaincode associated with reviews
+type ReviewChaincode struct {
+}
+
+// ReviewDecision is like an enum and shows what a reviewer decided
+type ReviewDecision int
+
+const (
+	// Pending means the reviewer has not decided yet
+	Pending ReviewDecision = 1 + iota
+	// Approved means the reviewer accepted the asset
+	Approved
+	// Declined means the reviewer rejected the asset (REJECTED in the model)
+	Declined
+	// Ignored means the review was closed before the reviewer decided
+	Ignored
+)
+
+var reviewDecisionNames = map[string]ReviewDecision{
+	"PENDING":  Pending,
+	"APPROVED": Approved,
+	"REJECTED": Declined,
+	"IGNORED":  Ignored,
+}
+
+// RejectReason is like an enum and shows why a reviewer rejected an asset
+type RejectReason int
+
+const (
+	// Inappropriate means the asset is offensive, spam or similar
+	Inappropriate RejectReason = 1 + iota
+	// Incorrect means the asset contains wrong data
+	Incorrect
+	// OutdatedData means the asset contains data that is not valid anymore (OUTDATED in the model)
+	OutdatedData
+	// Duplicate means the asset already exists
+	Duplicate
+	// MissingSource means the asset makes claims without providing a source
+	MissingSource
+	// OtherReason means the rejection is explained in the reason comment
+	OtherReason
+)
+
+var rejectReasonNames = map[string]RejectReason{
+	"INAPPROPRIATE": Inappropriate,
+	"INCORRECT":     Incorrect,
+	"OUTDATED":      OutdatedData,
+	"DUPLICATE":     Duplicate,
+	"MISSING_SRC":   MissingSource,
+	"OTHER":         OtherReason,
+}
+
+// Review is the asset representing the review of one appointed user for a reviewable asset
+type Review struct {
+	DocType       string         `json:"docType"`     // docType is used to distinguish the various types of objects in state database
+	Target        string         `json:"target"`      // key of the reviewed asset
+	User          string         `json:"user"`        /* user ID of the appointed reviewer */
+	RequestedBy   string         `json:"requestedBy"` // user ID of the user who submitted the asset or requested the change
+	RequestedAt   time.Time      `json:"requestedAt"`
+	ChangeReason  string         `json:"changeReason"` // reason of a deletion request (an edit carries it in the new version)
+	Decision      ReviewDecision `json:"decision"`     // default=PENDING
+	Timestamp     time.Time      `json:"timestamp"`
+	RejectReason  RejectReason   `json:"rejectReason"`  // optional
+	ReasonComment string         `json:"reasonComment"` // optional
+}
+
+// openReviewIndex is the composite key index of the reviews that are still
+// open, by target. Its entries are removed when the review is closed, so all
+// entries for one target belong to the same review round.
+const openReviewIndex = "target~review"
+
+// requestReview appoints randomly selected reviewers for the asset stored
+// under target. The change reason of a deletion request is kept in the
+// reviews until the review is closed. The users in exclude (e.g. creator and
+// updater of the asset) are not appointed.
+func requestReview(stub shim.ChaincodeStubInterface, target string, changeReason string, exclude ...string) error {
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	submitter, err := getRegisteredUser(stub)
+	if err != nil {
+		return err
+	}
+	reviewers, err := selectReviewers(stub, target, config.ReviewersPerAsset, submitter, config.MaxReviewsPerSubmitter, exclude)
+	if err != nil {
+		return err
+	}
+	if len(reviewers) < config.ReviewQuorum {
+		return fmt.Errorf("Not enough registered users to review %s: need at least %d, found %d",
+			target, config.ReviewQuorum, len(reviewers))
+	}
+	err = countReviews(stub, submitter, reviewers)
+	if err != nil {
+		return err
+	}
+
+	requestedAt, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	timestamp, _ := time.Parse(time.RFC3339, "1776-03-09T12:00:00Z")
+	for i, reviewer := range reviewers {
+		reviewKey := "review-" + stub.GetTxID() + "-" + strconv.Itoa(i)
+		review := &Review{"review", target, reviewer, submitter, requestedAt, changeReason, Pending, timestamp, 0, ""}
+		err = putAsset(stub, reviewKey, review)
+		if err != nil {
+			return err
+		}
+		indexKey, err := stub.CreateCompositeKey(openReviewIndex, []string{target, reviewKey})
+		if err != nil {
+			return err
+		}
+		err = stub.PutState(indexKey, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubmitReview records the decision of an appointed reviewer and closes the
+// review once enough reviewers approved or rejected the asset
+func (c *ReviewChaincode) SubmitReview(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0                           1                         2                                 3
+	// Review key,                Decision,                 Reject reason,                    Reason comment
+	// "review-2ab5f1c9...-0",    "APPROVED"/"REJECTED",    "" or "INCORRECT", "DUPLICATE",   "Price is wrong."
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4.")
+	}
+
+	user, err := getRegisteredUser(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// === Arg 0: Review key ===
+	reviewKey := args[0]
+	review, err := getReview(stub, reviewKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if review.User != user {
+		return shim.Error("Access denied. Review " + reviewKey + " is assigned to another user.")
+	}
+	if review.Decision != Pending {
+		return shim.Error("Review " + reviewKey + " has already been closed")
+	}
+
+	// === Arg 1: Decision ===
+	decision := reviewDecisionNames[args[1]]
+	if decision != Approved && decision != Declined {
+		return shim.Error("2nd argument 'decision' must be either \"APPROVED\" or \"REJECTED\"")
+	}
+
+	// === Args 2 and 3: Reject reason and reason comment ===
+	var rejectReason RejectReason
+	if decision == Declined {
+		var ok bool
+		rejectReason, ok = rejectReasonNames[args[2]]
+		if !ok {
+			return shim.Error("3rd argument 'rejectReason' must be one of \"INAPPROPRIATE\", " +
+				"\"INCORRECT\", \"OUTDATED\", \"DUPLICATE\", \"MISSING_SRC\" or \"OTHER\" when rejecting")
+		}
+	} else if len(args[2]) > 0 {
+		return shim.Error("3rd argument 'rejectReason' must be empty when approving")
+	}
+
+	timestamp, err := txTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	review.Decision = decision
+	review.Timestamp = timestamp
+	review.RejectReason = rejectReason
+	review.ReasonComment = args[3]
+	err = putAsset(stub, reviewKey, review)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = closeReviewIfDecided(stub, review.Target)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// closeReviewIfDecided counts the decisions of the open review round for
+// target. The review is closed as approved once the quorum of approvals is
+// reached, and as rejected once the quorum cannot be reached anymore.
+func closeReviewIfDecided(stub shim.ChaincodeStubInterface, target string) error {
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(openReviewIndex, []string{target})
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	var indexKeys, reviewKeys []string
+	var reviews []*Review
+	approvals, rejections := 0, 0
+	for resultsIterator.HasNext() {
+		indexEntry, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		_, attributes, err := stub.SplitCompositeKey(indexEntry.Key)
+		if err != nil {
+			return err
+		}
+		review, err := getReview(stub, attributes[1])
+		if err != nil {
+			return err
+		}
+		switch review.Decision {
+		case Approved:
+			approvals++
+		case Declined:
+			rejections++
+		}
+		indexKeys = append(indexKeys, indexEntry.Key)
+		reviewKeys = append(reviewKeys, attributes[1])
+		reviews = append(reviews, review)
+	}
+
+	// A panel smaller than the quorum (after a configuration change) must not block forever
+	quorum := config.ReviewQuorum
+	if quorum > len(reviews) {
+		quorum = len(reviews)
+	}
+	approved := approvals >= quorum
+	rejected := rejections > len(reviews)-quorum
+	if !approved && !rejected {
+		return nil
+	}
+
+	fmt.Printf("- closing review of %s: %d approvals, %d rejections\n", target, approvals, rejections)
+	err = closeReview(stub, target, approved, reviews[0])
+	if err != nil {
+		return err
+	}
+	for i, review := range reviews {
+		if review.Decision == Pending {
+			review.Decision = Ignored
+			err = putAsset(stub, reviewKeys[i], review)
+			if err != nil {
+				return err
+			}
+		}
+		err = stub.DelState(indexKeys[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryStringForUser returns the rich query for the reviews appointed to a
+// user with a decision. An empty user stands for the submitting user, so
+// `"", "PENDING"` returns the own review queue.
+func (c *ReviewChaincode) queryStringForUser(stub shim.ChaincodeStubInterface, user string, decisionName string) (string, error) {
+	if len(user) == 0 {
+		var err error
+		user, err = getRegisteredUser(stub)
+		if err != nil {
+			return "", err
+		}
+	}
+	decision, ok := reviewDecisionNames[decisionName]
+	if !ok {
+		return "", errors.New("'decision' must be one of \"PENDING\", \"APPROVED\", \"REJECTED\" or \"IGNORED\"")
+	}
+	return newSelector("review").eq("user", user).eq("decision", decision).queryStringWithIndex(indexReviewUserDoc, indexReviewUser)
+}
+
+// QueryReviewsByUser queries for the reviews appointed to a user with a decision
+func (c *ReviewChaincode) QueryReviewsByUser(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//                  0                              1
+	// User ID ("" for the submitting user),      "PENDING"
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+	queryString, err := c.queryStringForUser(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, false)
+}
+
+// QueryReviewsByUserWithPagination is the paginated variant of QueryReviewsByUser
+func (c *ReviewChaincode) QueryReviewsByUserWithPagination(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	//    0          1         2     3
+	// User ID,  "PENDING",  "20", bookmark ("" for the first page)
+	if len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 4")
+	}
+	queryString, err := c.queryStringForUser(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return queryResponse(stub, queryString, args, true)
+}
+
+// getReview reads the review stored under key from chaincode state
+func getReview(stub shim.ChaincodeStubInterface, key string) (*Review, error) {
+	jsonAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.New("Failed to get review: " + err.Error())
+	}
+	if jsonAsBytes == nil {
+		return nil, errors.New("Review " + key + " does not exist")
+	}
+	review := new(Review)
+	err = json.Unmarshal(jsonAsBytes, review)
+	if err != nil || review.DocType != "review" {
+		return nil, errors.New(key + " is not a review")
+	}
+	return review, nil
+}
+
+// contains reports whether s is in list
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,265 @@
+package viridian
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// Reviewer selection
+//
+// The reviewers of an asset are selected at random. Every endorsing peer must
+// come to the same selection, otherwise the endorsements do not match and the
+// transaction is invalidated. So instead of a random number generator, the
+// selection is seeded with data of the transaction proposal (transaction ID
+// and timestamp), which is the same on every peer.
+//
+// The seed is not out of the submitting client's control: the transaction ID
+// is the hash of a nonce chosen by the client and its identity, and the
+// client also chooses the timestamp. As the state is readable as well, a
+// client can simulate the selection offline and try proposals until users of
+// its choice are appointed. The chaincode cannot prevent this, so it limits
+// the gain instead: a user is appointed to review at most
+// Config.MaxReviewsPerSubmitter assets submitted by the same user. So a group
+// of colluding users can only push a bounded number of assets through review.
+
+// conflictIndex is the composite key index of the declared conflicts of
+// interest, by target. A user with an open conflict is not appointed to
+// review the target.
+const conflictIndex = "target~conflict"
+
+// reviewCountIndex is the composite key index of the number of times a user
+// was appointed to review an asset submitted by another user, by submitter
+const reviewCountIndex = "submitter~reviewer"
+
+// selectReviewers selects up to n registered users at random to review the
+// asset stored under target, which was submitted by submitter. Users in
+// exclude, users with an open conflict of interest and users who already
+// reviewed maxPerSubmitter assets of the submitter are not selected.
+func selectReviewers(stub shim.ChaincodeStubInterface, target string, n int, submitter string, maxPerSubmitter int, exclude []string) ([]string, error) {
+	ids, err := getRegisteredUserIDs(stub)
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := getConflictedUsers(stub, target)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := getReviewCounts(stub, submitter)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []string
+	for _, id := range ids {
+		if !contains(exclude, id) && !conflicts[id] && counts[id] < maxPerSubmitter {
+			candidates = append(candidates, id)
+		}
+	}
+
+	seed, err := selectionSeed(stub, target)
+	if err != nil {
+		return nil, err
+	}
+	// Partial Fisher-Yates shuffle: the first n candidates are the selection
+	for i := 0; i < n && i < len(candidates); i++ {
+		j := i + int(deterministicRandom(seed, i)%uint64(len(candidates)-i))
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	}
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates, nil
+}
+
+// selectionSeed derives the seed of the reviewer selection from the
+// transaction ID, the transaction timestamp and the reviewed asset
+func selectionSeed(stub shim.ChaincodeStubInterface, target string) ([]byte, error) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(stub.GetTxID()))
+	binary.Write(hash, binary.BigEndian, txTimestamp.GetSeconds())
+	binary.Write(hash, binary.BigEndian, txTimestamp.GetNanos())
+	hash.Write([]byte(target))
+	return hash.Sum(nil), nil
+}
+
+// deterministicRandom returns the i-th pseudo-random number derived from seed
+func deterministicRandom(seed []byte, i int) uint64 {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(i))
+	sum := sha256.Sum256(append(append([]byte{}, seed...), counter...))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// getReviewCounts returns how many assets of submitter each user was
+// appointed to review
+func getReviewCounts(stub shim.ChaincodeStubInterface, submitter string) (map[string]int, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(reviewCountIndex, []string{submitter})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	counts := make(map[string]int)
+	for resultsIterator.HasNext() {
+		indexEntry, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(indexEntry.Key)
+		if err != nil {
+			return nil, err
+		}
+		counts[attributes[1]], err = strconv.Atoi(string(indexEntry.Value))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// countReviews records that the reviewers were appointed to review an asset
+// of submitter
+func countReviews(stub shim.ChaincodeStubInterface, submitter string, reviewers []string) error {
+	counts, err := getReviewCounts(stub, submitter)
+	if err != nil {
+		return err
+	}
+	for _, reviewer := range reviewers {
+		indexKey, err := stub.CreateCompositeKey(reviewCountIndex, []string{submitter, reviewer})
+		if err != nil {
+			return err
+		}
+		err = stub.PutState(indexKey, []byte(strconv.Itoa(counts[reviewer]+1)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getConflictedUsers returns the users with an open conflict of interest
+// with the asset stored under target, with the version it supersedes or with
+// a producer of either version (e.g. employees of the producer of a product)
+func getConflictedUsers(stub shim.ChaincodeStubInterface, target string) (map[string]bool, error) {
+	targets := []string{target}
+	asset, err := getUpdatable(stub, target)
+	if err == nil {
+		versions := []updatable{asset}
+		if supersedes := asset.updatableAsset().Supersedes; len(supersedes) > 0 {
+			targets = append(targets, supersedes)
+			oldAsset, err := getUpdatable(stub, supersedes)
+			if err == nil {
+				versions = append(versions, oldAsset)
+			}
+		}
+		for _, version := range versions {
+			r, ok := version.(referencing)
+			if !ok {
+				continue
+			}
+			for _, ref := range r.references() {
+				if ref.DocType == "producer" && !contains(targets, ref.Key) {
+					targets = append(targets, ref.Key)
+				}
+			}
+		}
+	}
+
+	conflicts := make(map[string]bool)
+	for _, t := range targets {
+		resultsIterator, err := stub.GetStateByPartialCompositeKey(conflictIndex, []string{t})
+		if err != nil {
+			return nil, err
+		}
+		for resultsIterator.HasNext() {
+			indexEntry, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			_, attributes, err := stub.SplitCompositeKey(indexEntry.Key)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			conflicts[attributes[1]] = true
+		}
+		resultsIterator.Close()
+	}
+	return conflicts, nil
+}
+
+// DeclareConflict records that the submitting user has a conflict of interest
+// with an asset (e.g. works for the producer) and must not review it
+func (c *ReviewChaincode) DeclareConflict(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0                               1
+	// Asset key,                     Reason
+	// "producer-84a234b7-c9d8-...",  "I work for this company."
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2.")
+	}
+	user, err := getRegisteredUser(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// The reason is the value of the index entry, and an empty value would delete it
+	if len(strings.TrimSpace(args[1])) == 0 {
+		return shim.Error("Reason not provided")
+	}
+	target := args[0]
+	_, err = getUpdatable(stub, target)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	indexKey, err := stub.CreateCompositeKey(conflictIndex, []string{target, user})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(indexKey, []byte(args[1]))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Println("- declared conflict with " + target)
+	return shim.Success(nil)
+}
+
+// WithdrawConflict removes a conflict of interest declared by the submitting user
+func (c *ReviewChaincode) WithdrawConflict(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0
+	// Asset key
+	// "producer-84a234b7-c9d8-..."
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+	user, err := getRegisteredUser(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	indexKey, err := stub.CreateCompositeKey(conflictIndex, []string{args[0], user})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	existing, err := stub.GetState(indexKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing == nil {
+		return shim.Error("No conflict with " + args[0] + " declared")
+	}
+	err = stub.DelState(indexKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
@@ -0,0 +1,152 @@
+package viridian
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+)
+
+// CouchDB selectors
+//
+// Rich queries are built as typed Go values and marshalled with
+// encoding/json, never by formatting user input into a query string. So a
+// quote or a `"$or": [...]` in a name always ends up inside a JSON string and
+// cannot change the structure of the query.
+
+// selector is a CouchDB (Mango) selector, e.g. {"docType": "product", "gtin": "07612100055557"}
+type selector map[string]interface{}
+
+// newSelector returns a selector matching all assets of the given docType
+func newSelector(docType string) selector {
+	return selector{"docType": docType}
+}
+
+// eq adds the condition that field equals value. The value is marshalled as
+// is, so it must be a string, number, bool or (a slice of) selectors.
+func (s selector) eq(field string, value interface{}) selector {
+	s[field] = value
+	return s
+}
+
+// elemMatch adds the condition that the array field has at least one element
+// matching the element selector
+func (s selector) elemMatch(field string, element selector) selector {
+	s[field] = selector{"$elemMatch": element}
+	return s
+}
+
+// regex adds the condition that field matches the regular expression pattern
+func (s selector) regex(field string, pattern string) selector {
+	s[field] = selector{"$regex": pattern}
+	return s
+}
+
+// includes adds the condition that the array field has an element equal to value
+func (s selector) includes(field string, value interface{}) selector {
+	return s.elemMatch(field, selector{"$eq": value})
+}
+
+// name adds the condition on the field `name`. match is one of
+//   - "EXACT" (or ""): the name is equal
+//   - "PREFIX": the name starts with the given name, ignoring case
+//   - "SUBSTRING": the name contains the given name, ignoring case
+func (s selector) name(name string, match string) (selector, error) {
+	switch match {
+	case "", "EXACT":
+		s.eq("name", name)
+	case "PREFIX":
+		s.regex("name", "(?i)^"+regexp.QuoteMeta(name))
+	case "SUBSTRING":
+		s.regex("name", "(?i)"+regexp.QuoteMeta(name))
+	default:
+		return nil, errors.New("'match' must be one of \"EXACT\", \"PREFIX\" or \"SUBSTRING\"")
+	}
+	return s, nil
+}
+
+// localeName returns the selector for an element of `locales` with the given
+// name (see name), optionally only in the given language
+func localeName(name string, lang string, match string) (selector, error) {
+	locale, err := selector{}.name(name, match)
+	if err != nil {
+		return nil, err
+	}
+	if len(lang) > 0 {
+		locale.eq("lang", lang)
+	}
+	return locale, nil
+}
+
+// couchQuery is a CouchDB (Mango) query
+type couchQuery struct {
+	Selector selector `json:"selector"`
+	UseIndex []string `json:"use_index,omitempty"` // design document and name of the index
+}
+
+// queryString returns the JSON query string for the selector
+func (s selector) queryString() (string, error) {
+	return marshalQuery(couchQuery{Selector: s})
+}
+
+// queryStringWithIndex returns the JSON query string for the selector that
+// uses the index shipped in META-INF/statedb/couchdb/indexes with the given
+// design document and name
+func (s selector) queryStringWithIndex(ddoc string, name string) (string, error) {
+	return marshalQuery(couchQuery{s, []string{ddoc, name}})
+}
+
+func marshalQuery(query couchQuery) (string, error) {
+	jsonAsBytes, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonAsBytes), nil
+}
+
+// Indexes shipped in META-INF/statedb/couchdb/indexes. JSON indexes cannot
+// index the elements of arrays like `locales`, `producers` or `labels`, so
+// searches by locale name, producer, label or category use the docType index
+// and CouchDB filters the arrays of the matching documents.
+const (
+	indexDocTypeDoc     = "indexDocTypeDoc"
+	indexDocType        = "indexDocType" // docType
+	indexStatusDoc      = "indexStatusDoc"
+	indexStatus         = "indexStatus" // docType, status
+	indexNameDoc        = "indexNameDoc"
+	indexName           = "indexName" // docType, name (producers stored before they had locales)
+	indexTargetDoc      = "indexTargetDoc"
+	indexTarget         = "indexTarget" // docType, target (information, comments)
+	indexProductGTINDoc = "indexProductGTINDoc"
+	indexProductGTIN    = "indexProductGTIN" // docType, gtin
+	indexReviewUserDoc  = "indexReviewUserDoc"
+	indexReviewUser     = "indexReviewUser" // docType, user, decision
+)
+
+// couchIndex is a JSON index shipped in META-INF/statedb/couchdb/indexes
+type couchIndex struct {
+	ddoc   string
+	name   string
+	fields []string
+}
+
+// shippedIndexes lists every index in META-INF/statedb/couchdb/indexes. Ad
+// hoc queries must use one of them.
+var shippedIndexes = []couchIndex{
+	{indexDocTypeDoc, indexDocType, []string{"docType"}},
+	{indexStatusDoc, indexStatus, []string{"docType", "status"}},
+	{indexNameDoc, indexName, []string{"docType", "name"}},
+	{indexTargetDoc, indexTarget, []string{"docType", "target"}},
+	{indexProductGTINDoc, indexProductGTIN, []string{"docType", "gtin"}},
+	{indexReviewUserDoc, indexReviewUser, []string{"docType", "user", "decision"}},
+}
+
+// shippedIndex returns the shipped index with the given design document and
+// name, or nil if there is none
+func shippedIndex(ddoc string, name string) *couchIndex {
+	for i := range shippedIndexes {
+		if shippedIndexes[i].ddoc == ddoc && shippedIndexes[i].name == name {
+			return &shippedIndexes[i]
+		}
+	}
+	return nil
+}
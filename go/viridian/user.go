@@ -0,0 +1,138 @@
+package viridian
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// UserChaincode is the chaincode associated with users
+type UserChaincode struct {
+}
+
+// User is the participant representing a registered user. It links the
+// client identity (from the user's certificate) to a public user name.
+type User struct {
+	DocType    string    `json:"docType"` // docType is used to distinguish the various types of objects in state database
+	ID         string    `json:"id"`      // client identity as returned by cid.GetID, used in `createdBy` etc.
+	Name       string    `json:"name"`    // regex=/^[a-zA-Z0-9_\-.~|\/]+$/ // username shown publicly on platform, must be unique
+	CreatedAt  time.Time `json:"createdAt"`
+	Reputation int       `json:"reputation"`
+}
+
+// userNameIndex is the composite key index ensuring that user names are unique
+const userNameIndex = "name~user"
+
+var userNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-.~|/]+$`)
+
+// userKey returns the key under which the user with the given client identity is stored
+func userKey(id string) string {
+	return "user-" + id
+}
+
+// RegisterUser registers the submitting client identity as a user under the given name
+func (c *UserChaincode) RegisterUser(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	// Arguments:
+	//  0
+	// Name
+	// "alice"
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1.")
+	}
+
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error("Access denied. There is a problem with the client certificate.")
+	}
+
+	name := args[0]
+	if !userNameRegexp.MatchString(name) {
+		return shim.Error("User name must only contain the characters a-z, A-Z, 0-9, _, -, ., ~, | and /")
+	}
+
+	// ==== Check if user or user name already exists ====
+	existing, err := stub.GetState(userKey(id))
+	if err != nil {
+		return shim.Error("Failed to get user: " + err.Error())
+	}
+	if existing != nil {
+		return shim.Error("This identity is already registered")
+	}
+	nameIndexKey, err := stub.CreateCompositeKey(userNameIndex, []string{name})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	existing, err = stub.GetState(nameIndexKey)
+	if err != nil {
+		return shim.Error("Failed to get user: " + err.Error())
+	}
+	if existing != nil {
+		return shim.Error("User name " + name + " is already taken")
+	}
+
+	// ==== Save user and name index to state ====
+	createdAt, err := txTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	user := &User{"user", id, name, createdAt, 0}
+	err = putAsset(stub, userKey(id), user)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(nameIndexKey, []byte(userKey(id)))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fmt.Println("- registered user " + name)
+	return shim.Success(nil)
+}
+
+// getRegisteredUser returns the client identity of the submitting user and
+// fails if the user has not registered
+func getRegisteredUser(stub shim.ChaincodeStubInterface) (string, error) {
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return "", errors.New("Access denied. There is a problem with the client certificate.")
+	}
+	jsonAsBytes, err := stub.GetState(userKey(id))
+	if err != nil {
+		return "", errors.New("Failed to get user: " + err.Error())
+	}
+	if jsonAsBytes == nil {
+		return "", errors.New("Access denied. Submitting user is not registered.")
+	}
+	return id, nil
+}
+
+// getRegisteredUserIDs returns the client identities of all registered users,
+// sorted by key
+func getRegisteredUserIDs(stub shim.ChaincodeStubInterface) ([]string, error) {
+	resultsIterator, err := stub.GetStateByRange("user-", "user.")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var ids []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var user User
+		err = json.Unmarshal(queryResponse.Value, &user)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
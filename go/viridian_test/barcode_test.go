@@ -0,0 +1,48 @@
+package viridian_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian/barcode"
+)
+
+var _ = Describe("Barcode", func() {
+	DescribeTable("Normalizing valid GTINs to GTIN-14",
+		func(gtin string, expected string) {
+			normalized, err := barcode.Normalize(gtin)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(normalized).To(Equal(expected))
+		},
+		Entry("GTIN-8", "96385074", "00000096385074"),
+		Entry("UPC-A", "036000291452", "00036000291452"),
+		Entry("EAN-13", "7612100055557", "07612100055557"),
+		Entry("zero-padded EAN-13", "07612100055557", "07612100055557"),
+		Entry("GTIN-14", "10614141000415", "10614141000415"),
+	)
+
+	DescribeTable("Rejecting invalid GTINs",
+		func(gtin string, message string) {
+			_, err := barcode.Normalize(gtin)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(message))
+		},
+		Entry("empty", "", "must have 8"),
+		Entry("EAN-13 without check digit", "761210005555", "check digit"),
+		Entry("too short", "1234567", "must have 8"),
+		Entry("too long", "076121000555570", "must have 8"),
+		Entry("letters", "76121OOO55557", "only contain digits"),
+		Entry("letter as check digit", "761210005555X", "only contain digits"),
+		Entry("wrong check digit", "7612100055558", "wrong check digit, expected 7"),
+		Entry("spaces", "7612100 55557", "only contain digits"),
+	)
+
+	It("Should calculate GS1 check digits", func() {
+		checkDigit, err := barcode.CheckDigit("761210005555")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checkDigit).To(Equal(7))
+		_, err = barcode.CheckDigit("7612-0005555")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,146 @@
+package viridian_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+// addContainingArgs returns the arguments of addProduct for a product without
+// GTIN that contains the products stored under the given keys
+func addContainingArgs(uuid string, containedKeys ...string) []string {
+	args := addProductArgs(uuid, "")
+	containedJSON, _ := json.Marshal(containedKeys)
+	args[4] = string(containedJSON)
+	return args
+}
+
+var _ = Describe("Composition", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	const (
+		jarUUID  = "b1d2a2e4-43f4-4c27-a4a4-4b1a29a4ae01"
+		jarKey   = "product-" + jarUUID
+		boxUUID  = "b1d2a2e4-43f4-4c27-a4a4-4b1a29a4ae02"
+		boxKey   = "product-" + boxUUID
+		giftUUID = "b1d2a2e4-43f4-4c27-a4a4-4b1a29a4ae03"
+		giftKey  = "product-" + giftUUID
+	)
+
+	composition := func(key string) *viridian.CompositionNode {
+		response := stub.Invoke("query", "getProductComposition", key)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		node := new(viridian.CompositionNode)
+		Expect(json.Unmarshal(response.Payload, node)).To(Succeed())
+		return node
+	}
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", `{"reviewersPerAsset": 3, "reviewQuorum": 2, "maxCompositionDepth": 2}`)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+
+		response := stub.Invoke("001", addContainingArgs(jarUUID)...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		response = stub.Invoke("002", addContainingArgs(boxUUID, jarKey)...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	It("Should return the expanded tree with the status of every product", func() {
+		setStatus(stub, jarKey, viridian.Active)
+		response := stub.Invoke("003", addContainingArgs(giftUUID, boxKey, jarKey)...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+
+		root := composition(giftKey)
+		Expect(root.Key).To(Equal(giftKey))
+		Expect(root.Status).To(Equal(viridian.Preliminary))
+		Expect(root.ContainedProducts).To(HaveLen(2))
+		box := root.ContainedProducts[0]
+		Expect(box.Key).To(Equal(boxKey))
+		Expect(box.ContainedProducts).To(HaveLen(1))
+		Expect(box.ContainedProducts[0].Key).To(Equal(jarKey))
+		Expect(box.ContainedProducts[0].Status).To(Equal(viridian.Active))
+		Expect(box.ContainedProducts[0].ContainedProducts).To(BeEmpty())
+		Expect(root.ContainedProducts[1].Key).To(Equal(jarKey))
+	})
+
+	It("Should mark contained products that cannot be read", func() {
+		var box viridian.Product
+		Expect(stub.GetFixture(boxKey, &box)).To(BeTrue())
+		box.ContainedProducts = append(box.ContainedProducts, "product-does-not-exist", labelKey)
+		stub.PutFixture(boxKey, &box)
+
+		root := composition(boxKey)
+		Expect(root.ContainedProducts).To(HaveLen(3))
+		Expect(root.ContainedProducts[1].Problem).To(Equal("NOT_FOUND"))
+		Expect(root.ContainedProducts[2].Problem).To(Equal("WRONG_DOCTYPE"))
+	})
+
+	It("Should not expand a cycle in stored products", func() {
+		var jar viridian.Product
+		Expect(stub.GetFixture(jarKey, &jar)).To(BeTrue())
+		jar.ContainedProducts = []string{boxKey}
+		stub.PutFixture(jarKey, &jar)
+
+		root := composition(boxKey)
+		Expect(root.ContainedProducts[0].Key).To(Equal(jarKey))
+		Expect(root.ContainedProducts[0].ContainedProducts[0].Key).To(Equal(boxKey))
+		Expect(root.ContainedProducts[0].ContainedProducts[0].Problem).To(Equal("CYCLE"))
+	})
+
+	It("Should reject an edit that makes a product contain itself", func() {
+		setStatus(stub, jarKey, viridian.Active)
+		args := editProductArgs(jarKey, "The jar is sold in a box.", editUUID, "")
+		args[6] = `["` + boxKey + `"]`
+		response := stub.Invoke("003", args...)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("cycle: " + editKey + " -> " + boxKey + " -> " + jarKey))
+	})
+
+	It("Should reject products nested too deep", func() {
+		response := stub.Invoke("003", addContainingArgs(giftUUID, boxKey)...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		response = stub.Invoke("004", addContainingArgs(productUUID, giftKey)...)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("more than 2 levels deep"))
+	})
+
+	It("Should read every contained product only once when checking a composition", func() {
+		// Each level contains the level below twice, so the tree has 2^12 paths
+		stub.Init("000", reviewConfig)
+		below := jarKey
+		for i := 0; i < 12; i++ {
+			key := fmt.Sprintf("product-b1d2a2e4-43f4-4c27-a4a4-4b1a29a4b%03d", i)
+			stub.PutFixture(key, &viridian.Product{DocType: "product", ContainedProducts: []string{below, below}})
+			below = key
+		}
+		stub.Reads = nil
+		response := stub.Invoke("003", addContainingArgs(giftUUID, below)...)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("more than 10 levels deep"))
+		Expect(len(stub.Reads)).To(BeNumerically("<", 100))
+
+		stub.Init("000", `{"reviewersPerAsset": 3, "reviewQuorum": 2, "maxCompositionDepth": 20}`)
+		stub.Reads = nil
+		response = stub.Invoke("004", addContainingArgs(giftUUID, below)...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		Expect(len(stub.Reads)).To(BeNumerically("<", 100))
+
+		response = stub.Invoke("005", "getProductComposition", giftKey)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("more than 1000 products"))
+	})
+
+	It("Should reject an unknown product", func() {
+		response := stub.Invoke("003", "getProductComposition", "product-does-not-exist")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("does not exist"))
+	})
+})
@@ -0,0 +1,77 @@
+package viridian_test
+
+import (
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+// Every endorsing peer executes the same transaction proposal independently.
+// The endorsements only match if all peers write byte-identical state.
+var _ = Describe("Endorsement", func() {
+	var peer1, peer2 *testStub
+	proposalTime := &timestamp.Timestamp{Seconds: 1555555555, Nanos: 123456789}
+
+	// endorse executes the same proposal on both peers, each of which
+	// takes its time at a different moment
+	endorse := func(txID string, creator string, args ...string) {
+		for _, peer := range []*testStub{peer1, peer2} {
+			peer.SetCreator(creator)
+			response := peer.Invoke(txID, args...)
+			Expect(response.Status).Should(Equal(int32(200)), response.Message)
+			time.Sleep(2 * time.Millisecond)
+		}
+		Expect(peer1.State).To(Equal(peer2.State))
+	}
+
+	BeforeEach(func() {
+		peer1 = newTestStub()
+		peer2 = newTestStub()
+		for _, peer := range []*testStub{peer1, peer2} {
+			peer.TxTimestamp = proposalTime
+			peer.Init("000", reviewConfig)
+			putReferencedAssets(peer)
+		}
+		for _, name := range []string{"user1", "user2", "user3", "user4", "user5"} {
+			endorse("register-"+name, name, "registerUser", name)
+		}
+	})
+
+	It("Should produce identical state for addProduct", func() {
+		endorse("001", "user1", addProductArgs(productUUID, "7612100055557")...)
+
+		var product viridian.Product
+		Expect(peer1.GetFixture(productKey, &product)).To(BeTrue())
+		Expect(product.CreatedAt.Unix()).To(Equal(proposalTime.Seconds))
+	})
+
+	It("Should produce identical state for initProducer", func() {
+		endorse("001", "user1", "initProducer", wanderUUID, wanderLocales, "[]")
+	})
+
+	It("Should produce identical state for reviews, edits and deletions", func() {
+		// decide lets two of the appointed reviewers of target submit the decision
+		decide := func(target string, decision string, rejectReason string) {
+			reviews := openReviews(peer1, target)
+			var names []string
+			for name := range reviews {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names[:2] {
+				endorse("decide-"+reviews[name], name, "submitReview", reviews[name], decision, rejectReason, "")
+			}
+		}
+
+		endorse("001", "user1", addProductArgs(productUUID, "7612100055557")...)
+		decide(productKey, "APPROVED", "")
+		endorse("002", "user2", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+		decide(editKey, "REJECTED", "INCORRECT")
+		endorse("003", "user2", "deleteProduct", productKey, "This product does not exist.")
+	})
+})
@@ -0,0 +1,101 @@
+package viridian_test
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+var _ = Describe("History", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	// at makes the following transactions happen the given number of
+	// seconds after the first one
+	at := func(seconds int64) {
+		stub.TxTimestamp = &timestamp.Timestamp{Seconds: 1555555555 + seconds}
+	}
+
+	history := func(function string, key string) []viridian.HistoryEntry {
+		response := stub.Invoke("history", function, key)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		var entries []viridian.HistoryEntry
+		Expect(json.Unmarshal(response.Payload, &entries)).To(Succeed())
+		return entries
+	}
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		at(0)
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		at(10)
+		decide(stub, productKey, "APPROVED", 2)
+	})
+
+	It("Should list every committed state of a product", func() {
+		entries := history("getHistoryForProduct", productKey)
+		Expect(entries).To(HaveLen(2)) // added, activated by the closing review
+		Expect(entries[0].TxID).To(Equal("001"))
+		Expect(entries[0].Timestamp.Unix()).To(Equal(int64(1555555555)))
+		Expect(entries[0].IsDelete).To(BeFalse())
+		var product viridian.Product
+		Expect(json.Unmarshal(entries[0].Value, &product)).To(Succeed())
+		Expect(product.Status).To(Equal(viridian.Preliminary))
+		Expect(json.Unmarshal(entries[1].Value, &product)).To(Succeed())
+		Expect(product.Status).To(Equal(viridian.Active))
+	})
+
+	It("Should merge the histories of all versions of a product", func() {
+		at(20)
+		stub.SetCreator("user2")
+		response := stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		at(30)
+		decide(stub, editKey, "APPROVED", 2)
+
+		// Entries of the same transaction are ordered from the old to the new version
+		expected := []struct {
+			key     string
+			seconds int64
+			status  viridian.Status
+		}{
+			{productKey, 0, viridian.Preliminary},
+			{productKey, 10, viridian.Active},
+			{productKey, 20, viridian.Active}, // supersededBy set
+			{editKey, 20, viridian.Preliminary},
+			{productKey, 30, viridian.Outdated},
+			{editKey, 30, viridian.Active},
+		}
+		for _, key := range []string{productKey, editKey} {
+			entries := history("getHistoryForProduct", key)
+			Expect(entries).To(HaveLen(len(expected)))
+			for i, entry := range entries {
+				var product viridian.Product
+				Expect(json.Unmarshal(entry.Value, &product)).To(Succeed())
+				Expect(entry.Key).To(Equal(expected[i].key))
+				Expect(entry.Timestamp.Unix() - 1555555555).To(Equal(expected[i].seconds))
+				Expect(product.Status).To(Equal(expected[i].status))
+			}
+		}
+	})
+
+	It("Should return the history of producers and labels", func() {
+		Expect(history("getHistoryForProducer", producerKey)).To(HaveLen(1))
+		Expect(history("getHistoryForLabel", labelKey)).To(HaveLen(1))
+	})
+
+	It("Should reject a key of another type", func() {
+		response := stub.Invoke("002", "getHistoryForLabel", productKey)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("is not a label"))
+	})
+})
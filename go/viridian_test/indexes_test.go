@@ -0,0 +1,120 @@
+package viridian_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+var _ = Describe("CouchDB indexes", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	// keys returns the keys of the assets in a (non-paginated) query result
+	keys := func(response []byte) []string {
+		var records []viridian.QueryRecord
+		Expect(json.Unmarshal(response, &records)).To(Succeed())
+		keys := []string{}
+		for _, record := range records {
+			keys = append(keys, record.Key)
+		}
+		return keys
+	}
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		stub.SetCreator("user1")
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	It("Should have a valid definition for every index", func() {
+		indexes, err := shippedIndexes()
+		Expect(err).NotTo(HaveOccurred())
+		names := map[string]bool{}
+		for _, index := range indexes {
+			Expect(index.Type).To(Equal("json"), index.Name)
+			Expect(index.Index.Fields).NotTo(BeEmpty(), index.Name)
+			Expect(index.Index.Fields[0]).To(Equal("docType"), index.Name)
+			Expect(names).NotTo(HaveKey(index.Name))
+			names[index.Name] = true
+		}
+	})
+
+	// Every query of the chaincode must use a shipped index that CouchDB can
+	// use for its selector. The test stub checks this for every query (see
+	// checkIndex), so the entries only need to run each query once.
+	DescribeTable("Should run every query on a shipped index",
+		func(args []string, expected []string) {
+			response := stub.Invoke("002", args...)
+			Expect(response.Status).Should(Equal(status200), response.Message)
+			Expect(stub.Queries).To(HaveLen(1))
+			if expected != nil {
+				Expect(keys(response.Payload)).To(Equal(expected))
+			}
+		},
+		Entry("products by GTIN", []string{"queryProductsByGTIN", "7612100055557"}, []string{productKey}),
+		Entry("products by name", []string{"queryProductsByName", "ovomaltine", "de", "PREFIX"}, []string{productKey}),
+		Entry("products by producer", []string{"queryProductsByProducer", producerKey}, []string{productKey}),
+		Entry("products by status", []string{"queryProductsByStatus", "PRELIMINARY"}, []string{productKey}),
+		Entry("active products", []string{"queryProductsByStatus", "ACTIVE"}, []string{}),
+		Entry("products by label", []string{"queryProductsByLabel", labelKey}, []string{productKey}),
+		Entry("products by another label", []string{"queryProductsByLabel", "label-0"}, []string{}),
+		Entry("products by category", []string{"queryProductsByCategory", "Brotaufstrich", "de"}, []string{}),
+		Entry("producers by name", []string{"queryProducersByName", "wan", "", "PREFIX"}, []string{producerKey}),
+		Entry("labels by name", []string{"queryLabelsByName", "Knospe"}, nil),
+		Entry("reviews by user", []string{"queryReviewsByUser", "", "APPROVED"}, []string{}),
+		Entry("information by target", []string{"queryInformationByTarget", productKey}, []string{}),
+		Entry("comments by target", []string{"queryCommentsByTarget", productKey}, []string{}),
+		Entry("paginated products by producer", []string{"queryProductsByProducerWithPagination", producerKey, "10", ""}, nil),
+		Entry("paginated products by status", []string{"queryProductsByStatusWithPagination", "ACTIVE", "10", ""}, nil),
+		Entry("paginated products by label", []string{"queryProductsByLabelWithPagination", labelKey, "10", ""}, nil),
+		Entry("paginated products by category", []string{"queryProductsByCategoryWithPagination", "Brotaufstrich", "", "10", ""}, nil),
+		Entry("paginated producers by name", []string{"queryProducersByNameWithPagination", "Wander AG", "de", "", "10", ""}, nil),
+		Entry("paginated reviews by user", []string{"queryReviewsByUserWithPagination", "", "PENDING", "10", ""}, nil),
+		Entry("paginated information by target", []string{"queryInformationByTargetWithPagination", productKey, "10", ""}, nil),
+		Entry("paginated comments by target", []string{"queryCommentsByTargetWithPagination", productKey, "10", ""}, nil),
+	)
+
+	It("Should return the review queue of a user", func() {
+		reviewer, reviewKey := anyReview(stub, productKey)
+		stub.SetCreator(reviewer)
+		response := stub.Invoke("002", "queryReviewsByUser", "", "PENDING")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		var reviews []struct {
+			Key   string
+			Value viridian.Review
+		}
+		Expect(json.Unmarshal(response.Payload, &reviews)).To(Succeed())
+		Expect(reviews).To(HaveLen(1))
+		Expect(reviews[0].Key).To(Equal(reviewKey))
+		Expect(reviews[0].Value.Target).To(Equal(productKey))
+		Expect(reviews[0].Value.Decision).To(Equal(viridian.Pending))
+
+		response = stub.Invoke("003", "queryReviewsByUser", "", "APPROVED")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		Expect(keys(response.Payload)).To(BeEmpty())
+	})
+
+	DescribeTable("Should reject invalid query arguments",
+		func(args []string, message string) {
+			response := stub.Invoke("002", args...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring(message))
+			Expect(stub.Queries).To(BeEmpty())
+		},
+		Entry("an unknown status", []string{"queryProductsByStatus", "active"}, "'status'"),
+		Entry("an unknown decision", []string{"queryReviewsByUser", "", "DONE"}, "'decision'"),
+		Entry("an unknown match", []string{"queryProducersByName", "Wander", "", "FUZZY"}, "'match'"),
+		Entry("a missing argument", []string{"queryProductsByProducer"}, "Expecting 1"),
+		Entry("a missing page size", []string{"queryProductsByLabelWithPagination", labelKey, ""}, "Expecting 3"),
+	)
+})
@@ -0,0 +1,110 @@
+package viridian_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+// uuidKeysConfig is reviewConfig with UUID keys required
+const uuidKeysConfig = `{"reviewersPerAsset": 3, "reviewQuorum": 2, "requireUuidKeys": true}`
+
+// assetWriter is a function that stores a new asset under a key chosen by
+// the client
+type assetWriter struct {
+	name    string
+	docType string
+	args    func(key string) []string
+	usedKey string // key of an asset of the docType that is already stored
+}
+
+var assetWriters = []assetWriter{
+	{"addProduct", "product", func(key string) []string {
+		return addProductArgs(key, "")
+	}, productUUID},
+	{"editProduct", "product", func(key string) []string {
+		return editProductArgs(productKey, "Wrong quantity information.", key, "")
+	}, productUUID},
+	{"initProducer", "producer", func(key string) []string {
+		return []string{"initProducer", key, wanderLocales, "[]"}
+	}, strings.TrimPrefix(producerKey, "producer-")},
+	{"addLabel", "label", func(key string) []string {
+		return []string{"addLabel", key, bioLocales, "2019"}
+	}, strings.TrimPrefix(labelKey, "label-")},
+	{"editLabel", "label", func(key string) []string {
+		return []string{"editLabel", labelKey, "New criteria published.", key, bioLocales, "2020"}
+	}, strings.TrimPrefix(labelKey, "label-")},
+}
+
+var _ = Describe("Asset keys", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		setStatus(stub, productKey, viridian.Active)
+	})
+
+	for _, w := range assetWriters {
+		w := w
+
+		Describe(w.name, func() {
+			DescribeTable("Should reject the key",
+				func(key string, message string) {
+					before := len(stub.State)
+					response := stub.Invoke("002", w.args(key)...)
+					Expect(response.Status).Should(Equal(status500))
+					Expect(response.Message).To(ContainSubstring(message))
+					Expect(stub.State).To(HaveLen(before))
+				},
+				Entry("when it is empty", "", "key not provided"),
+				Entry("when it contains a slash", "8a259c61/6825", "malformed"),
+				Entry("when it contains the composite key delimiter", "8a259c61\x006825", "malformed"),
+				Entry("when it contains spaces", "8a259c61 6825", "malformed"),
+				Entry("when it is too long", strings.Repeat("a", 65), "malformed"),
+				Entry("when it is in use", w.usedKey, "with key "+w.docType+"-"+w.usedKey+" already exists"),
+			)
+
+			It("Should store the asset under docType and key", func() {
+				response := stub.Invoke("002", w.args("my-key-1")...)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				Expect(stub.State).To(HaveKey(w.docType + "-my-key-1"))
+			})
+
+			Context("when UUID keys are required", func() {
+				BeforeEach(func() {
+					response := stub.Init("003", uuidKeysConfig)
+					Expect(response.Status).Should(Equal(status200), response.Message)
+				})
+
+				DescribeTable("Should reject keys that are not lowercase UUIDs (version 4)",
+					func(key string) {
+						response := stub.Invoke("004", w.args(key)...)
+						Expect(response.Status).Should(Equal(status500))
+						Expect(response.Message).To(ContainSubstring("must be a lowercase UUID"))
+					},
+					Entry("any name", "my-key-1"),
+					Entry("uppercase", "8A259C61-6825-4B3C-9A1E-2F0B5C1D7E34"),
+					Entry("version 1", "8a259c61-6825-1b3c-9a1e-2f0b5c1d7e34"),
+					Entry("wrong variant", "8a259c61-6825-4b3c-7a1e-2f0b5c1d7e34"),
+					Entry("without hyphens", "8a259c6168254b3c9a1e2f0b5c1d7e34"),
+				)
+
+				It("Should accept a UUID (version 4)", func() {
+					response := stub.Invoke("004", w.args("8a259c61-6825-4b3c-9a1e-2f0b5c1d7e34")...)
+					Expect(response.Status).Should(Equal(status200), response.Message)
+				})
+			})
+		})
+	}
+})
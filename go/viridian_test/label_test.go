@@ -0,0 +1,127 @@
+package viridian_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+const (
+	bioUUID     = "5b8c1e8e-2f57-4f0f-9a4e-0d7c8f3a1b01"
+	bioKey      = "label-" + bioUUID
+	bioEditUUID = "5b8c1e8e-2f57-4f0f-9a4e-0d7c8f3a1b02"
+	bioEditKey  = "label-" + bioEditUUID
+	bioLocales  = `[{"lang": "de", "name": "Knospe", "description": "Bio Suisse", "categories": ["Bio"], "logoUrls": ["https://www.bio-suisse.ch/logo.png"], "urls": ["https://www.bio-suisse.ch/"]}, {"lang": "fr", "name": "Bourgeon"}]`
+)
+
+var _ = Describe("Label", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		response := stub.Invoke("001", "addLabel", bioUUID, bioLocales, "2019")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	It("Should add a preliminary label and appoint reviewers", func() {
+		var label viridian.Label
+		Expect(stub.GetFixture(bioKey, &label)).To(BeTrue())
+		Expect(label.Status).To(Equal(viridian.Preliminary))
+		Expect(label.Version).To(Equal("2019"))
+		Expect(label.Locales[0].LogoURLs).To(Equal([]string{"https://www.bio-suisse.ch/logo.png"}))
+		Expect(label.Locales[0].URLs).To(Equal([]string{"https://www.bio-suisse.ch/"}))
+		Expect(openReviews(stub, bioKey)).To(HaveLen(3))
+
+		decide(stub, bioKey, "APPROVED", 2)
+		Expect(stub.GetFixture(bioKey, &label)).To(BeTrue())
+		Expect(label.Status).To(Equal(viridian.Active))
+	})
+
+	It("Should require at least one locale", func() {
+		response := stub.Invoke("002", "addLabel", bioEditUUID, "[]", "")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("locale"))
+	})
+
+	It("Should read a label", func() {
+		response := stub.Invoke("002", "readLabel", bioKey)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		var label viridian.Label
+		Expect(json.Unmarshal(response.Payload, &label)).To(Succeed())
+		Expect(label.Locales[1].Name).To(Equal("Bourgeon"))
+
+		response = stub.Invoke("003", "readLabel", "label-does-not-exist")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("does not exist"))
+	})
+
+	It("Should query labels by name in any or one language", func() {
+		var results []struct {
+			Key string
+		}
+		response := stub.Invoke("002", "queryLabelsByName", "Bourgeon")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		Expect(json.Unmarshal(response.Payload, &results)).To(Succeed())
+		Expect(results).To(HaveLen(1))
+		Expect(results[0].Key).To(Equal(bioKey))
+
+		response = stub.Invoke("003", "queryLabelsByName", "Bourgeon", "fr")
+		Expect(json.Unmarshal(response.Payload, &results)).To(Succeed())
+		Expect(results).To(HaveLen(1))
+
+		response = stub.Invoke("004", "queryLabelsByName", "Bourgeon", "de")
+		Expect(json.Unmarshal(response.Payload, &results)).To(Succeed())
+		Expect(results).To(BeEmpty())
+	})
+
+	Describe("Editing a label", func() {
+		It("Should not edit a preliminary label", func() {
+			response := stub.Invoke("002", "editLabel", bioKey, "New criteria.", bioEditUUID, bioLocales, "2020")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("not active"))
+		})
+
+		It("Should outdate the old version when the edit is approved", func() {
+			decide(stub, bioKey, "APPROVED", 2)
+			stub.SetCreator("user2")
+			response := stub.Invoke("002", "editLabel", bioKey, "New criteria.", bioEditUUID, bioLocales, "2020")
+			Expect(response.Status).Should(Equal(status200), response.Message)
+
+			reviews := openReviews(stub, bioEditKey)
+			Expect(reviews).NotTo(HaveKey("user1"))
+			Expect(reviews).NotTo(HaveKey("user2"))
+			decide(stub, bioEditKey, "APPROVED", 2)
+
+			var oldLabel, newLabel viridian.Label
+			Expect(stub.GetFixture(bioKey, &oldLabel)).To(BeTrue())
+			Expect(stub.GetFixture(bioEditKey, &newLabel)).To(BeTrue())
+			Expect(oldLabel.Status).To(Equal(viridian.Outdated))
+			Expect(newLabel.Status).To(Equal(viridian.Active))
+			Expect(newLabel.Supersedes).To(Equal(bioKey))
+			Expect(newLabel.Version).To(Equal("2020"))
+			Expect(newLabel.CreatedBy).To(Equal(oldLabel.CreatedBy))
+		})
+	})
+
+	It("Should be referenceable by products unless rejected", func() {
+		stub.PutFixture(producerKey, &viridian.Producer{DocType: "producer"})
+		args := addProductArgs(productUUID, "7612100055557")
+		args[6] = `["` + bioKey + `"]`
+		response := stub.Invoke("002", args...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+
+		decide(stub, bioKey, "REJECTED", 2)
+		args = addProductArgs(editUUID, "")
+		args[6] = `["` + bioKey + `"]`
+		response = stub.Invoke("003", args...)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("REJECTED"))
+	})
+})
@@ -0,0 +1,100 @@
+package viridian_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locales", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+	})
+
+	// addProduct adds a product with the locales and returns the response
+	addProduct := func(locales string) (int32, string) {
+		args := addProductArgs(productUUID, "")
+		args[7] = locales
+		response := stub.Invoke("001", args...)
+		return response.Status, response.Message
+	}
+
+	// addLabel adds a label with the locales and returns the response
+	addLabel := func(locales string) (int32, string) {
+		response := stub.Invoke("001", "addLabel", bioUUID, locales, "")
+		return response.Status, response.Message
+	}
+
+	// initProducer adds a producer with the locales and returns the response
+	initProducer := func(locales string) (int32, string) {
+		response := stub.Invoke("001", "initProducer", wanderUUID, locales, "[]")
+		return response.Status, response.Message
+	}
+
+	for _, asset := range []struct {
+		name string
+		add  func(locales string) (int32, string)
+	}{{"of products", addProduct}, {"of labels", addLabel}, {"of producers", initProducer}} {
+		add := asset.add
+
+		Describe(asset.name, func() {
+			DescribeTable("Should accept ISO 639-1 codes with an optional region subtag",
+				func(lang string) {
+					status, message := add(`[{"lang": "` + lang + `", "name": "Ovomaltine"}]`)
+					Expect(status).Should(Equal(status200), message)
+				},
+				Entry("language", "de"),
+				Entry("language and country", "de-CH"),
+				Entry("language and UN M.49 area", "es-419"),
+				Entry("last code of the list", "zu"),
+			)
+
+			DescribeTable("Should reject an invalid lang",
+				func(lang string, problem string) {
+					status, message := add(`[{"lang": "` + lang + `", "name": "Ovomaltine"}]`)
+					Expect(status).Should(Equal(status500))
+					Expect(message).To(ContainSubstring(`{"field":"locales[0].lang","value":"` + lang + `","problem":"` + problem + `"}`))
+				},
+				Entry("empty", "", "MISSING"),
+				Entry("uppercase", "DE", "MALFORMED"),
+				Entry("ISO 639-2 code", "deu", "MALFORMED"),
+				Entry("language name", "german", "MALFORMED"),
+				Entry("lowercase region", "de-ch", "MALFORMED"),
+				Entry("underscore", "de_CH", "MALFORMED"),
+				Entry("script subtag", "zh-Hant", "MALFORMED"),
+				Entry("not in ISO 639-1", "xx", "UNKNOWN_LANGUAGE"),
+				Entry("withdrawn code", "bh", "UNKNOWN_LANGUAGE"),
+				Entry("not in ISO 639-1 with region", "xx-CH", "UNKNOWN_LANGUAGE"),
+			)
+
+			It("Should require at least one locale", func() {
+				status, message := add(`[]`)
+				Expect(status).Should(Equal(status500))
+				Expect(message).To(ContainSubstring(`1 invalid locale field(s): {"invalidFields":[{"field":"locales","value":"","problem":"MISSING"}]}`))
+			})
+
+			It("Should list every invalid field", func() {
+				status, message := add(`[{"lang": "de", "name": "Ovomaltine"}, {"lang": "fr", "name": " "}, {"lang": "de", "name": "Ovo"}, {"lang": "DE"}]`)
+				Expect(status).Should(Equal(status500))
+				Expect(message).To(ContainSubstring(`4 invalid locale field(s)`))
+				Expect(message).To(ContainSubstring(`{"field":"locales[1].name","value":" ","problem":"MISSING"}`))
+				Expect(message).To(ContainSubstring(`{"field":"locales[2].lang","value":"de","problem":"DUPLICATE"}`))
+				Expect(message).To(ContainSubstring(`{"field":"locales[3].lang","value":"DE","problem":"MALFORMED"}`))
+				Expect(message).To(ContainSubstring(`{"field":"locales[3].name","value":"","problem":"MISSING"}`))
+				Expect(message).NotTo(ContainSubstring(`"locales[0]`))
+			})
+
+			It("Should accept the same language for different regions", func() {
+				status, message := add(`[{"lang": "de", "name": "Ovomaltine"}, {"lang": "de-CH", "name": "Ovomaltine"}, {"lang": "de-AT", "name": "Ovomaltine"}]`)
+				Expect(status).Should(Equal(status200), message)
+			})
+		})
+	}
+})
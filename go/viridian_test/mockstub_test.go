@@ -0,0 +1,24 @@
+package viridian_test
+
+import (
+	"github.com/chaincode/viridian/go/testsupport"
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+// indexDir holds the CouchDB indexes shipped with the chaincode. Every rich
+// query run in a test must use one of them (see testsupport.CheckIndex).
+const indexDir = "../META-INF/statedb/couchdb/indexes"
+
+// testStub is the stub all tests run the chaincode on
+type testStub = testsupport.Stub
+
+func newTestStub() *testStub {
+	stub := testsupport.NewStub(new(viridian.Chaincode))
+	stub.IndexDir = indexDir
+	return stub
+}
+
+// shippedIndexes returns the definitions of the indexes shipped with the chaincode
+func shippedIndexes() ([]testsupport.CouchIndex, error) {
+	return testsupport.ShippedIndexes(indexDir)
+}
@@ -0,0 +1,153 @@
+package viridian_test
+
+import (
+	"sort"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+	"github.com/chaincode/viridian/go/viridian/money"
+)
+
+var _ = Describe("Money", func() {
+	DescribeTable("Normalizing currencies to ISO 4217 codes",
+		func(currency string, expected string) {
+			code, err := money.NormalizeCurrency(currency)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(code).To(Equal(expected))
+		},
+		Entry("code", "EUR", "EUR"),
+		Entry("lowercase code", "chf", "CHF"),
+		Entry("code with spaces", " USD ", "USD"),
+		Entry("euro sign", "€", "EUR"),
+		Entry("pound sign", "£", "GBP"),
+		Entry("dollar sign", "$", "USD"),
+		Entry("Swiss franc abbreviation", "Fr.", "CHF"),
+		Entry("złoty", "zł", "PLN"),
+	)
+
+	DescribeTable("Rejecting unknown currencies",
+		func(currency string) {
+			_, err := money.NormalizeCurrency(currency)
+			Expect(err).To(MatchError(ContainSubstring("neither an ISO 4217 code")))
+		},
+		Entry("empty", ""),
+		Entry("name", "Euro"),
+		Entry("unknown code", "XYZ"),
+		Entry("withdrawn code", "DEM"),
+		Entry("ambiguous symbol", "kr"),
+	)
+
+	DescribeTable("Parsing prices into minor units",
+		func(price string, currency string, value int64, code string) {
+			amount, err := money.Parse(price, currency)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(amount).To(Equal(money.Amount{Value: value, Currency: code}))
+		},
+		Entry("decimal point", "4.99", "EUR", int64(499), "EUR"),
+		Entry("decimal comma", "4,99", "€", int64(499), "EUR"),
+		Entry("one decimal place", "4.5", "EUR", int64(450), "EUR"),
+		Entry("integer", "4", "EUR", int64(400), "EUR"),
+		Entry("leading decimal point", ".50", "EUR", int64(50), "EUR"),
+		Entry("zero", "0.00", "EUR", int64(0), "EUR"),
+		Entry("Swiss whole amount", "4.–", "CHF", int64(400), "CHF"),
+		Entry("Swiss whole amount with hyphen", "12.-", "CHF", int64(1200), "CHF"),
+		Entry("apostrophes grouping thousands", "1'234.50", "CHF", int64(123450), "CHF"),
+		Entry("spaces grouping thousands", "1 234,50", "EUR", int64(123450), "EUR"),
+		Entry("points grouping thousands", "1.234.567,89", "EUR", int64(123456789), "EUR"),
+		Entry("commas grouping thousands", "1,234,567.89", "USD", int64(123456789), "USD"),
+		Entry("single separator grouping thousands with decimal places", "1.234,00", "EUR", int64(123400), "EUR"),
+		Entry("currency without minor unit", "1,000", "¥", int64(1000), "JPY"),
+		Entry("currency with three decimal places", "1.234", "KWD", int64(1234), "KWD"),
+		Entry("surrounding spaces", " 4.99 ", "EUR", int64(499), "EUR"),
+	)
+
+	DescribeTable("Rejecting malformed prices",
+		func(price string, currency string, message string) {
+			_, err := money.Parse(price, currency)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("empty", "", "EUR", "empty"),
+		Entry("words", "cheap", "EUR", "no digits"),
+		Entry("letters", "4.9O", "EUR", "not a digit"),
+		Entry("negative", "-4.99", "EUR", "not a digit"),
+		Entry("currency symbol", "€4.99", "EUR", "not a digit"),
+		Entry("separator only", ".", "EUR", "no digits"),
+		Entry("too many decimal places", "4.9999", "KWD", "more than 3 decimal places"),
+		Entry("decimal places of a currency without minor unit", "4.5", "JPY", "more than 0 decimal places"),
+		Entry("cents of cents", "4.9999", "EUR", "more than 2 decimal places"),
+		Entry("wrong grouping", "12.34.567", "EUR", "group three digits"),
+		Entry("leading zero group", "0.123.456", "EUR", "group three digits"),
+		Entry("fraction of a cent", "0.125", "EUR", "more than 2 decimal places"),
+		Entry("trailing zero or thousands", "4.990", "EUR", `the "." is ambiguous`),
+		Entry("single separator grouping thousands", "1.234", "EUR", `write thousands without separator ("1234") or with decimal places ("1.234,00")`),
+		Entry("single comma grouping thousands", "1,234", "USD", `the "," is ambiguous`),
+		Entry("mixed grouping", "1.234,567.89", "USD", "one separator"),
+		Entry("separator at the start", ",234.50", "USD", "between digits"),
+		Entry("too large", "99999999999999999999", "EUR", "too large"),
+		Entry("unknown currency", "4.99", "Euro", "neither an ISO 4217 code"),
+	)
+
+	It("Should format and compare amounts", func() {
+		amounts := []money.Amount{{Value: 499, Currency: "EUR"}, {Value: 1050, Currency: "EUR"}, {Value: 5, Currency: "EUR"}}
+		sort.Slice(amounts, func(i, j int) bool {
+			c, err := amounts[i].Compare(amounts[j])
+			Expect(err).NotTo(HaveOccurred())
+			return c < 0
+		})
+		Expect([]string{amounts[0].String(), amounts[1].String(), amounts[2].String()}).To(Equal([]string{"0.05 EUR", "4.99 EUR", "10.50 EUR"}))
+		Expect(money.Amount{Value: 1000, Currency: "JPY"}.String()).To(Equal("1000 JPY"))
+		Expect(money.Amount{Value: 1234, Currency: "KWD"}.String()).To(Equal("1.234 KWD"))
+
+		_, err := amounts[0].Compare(money.Amount{Value: 5, Currency: "CHF"})
+		Expect(err).To(MatchError(ContainSubstring("cannot compare")))
+	})
+
+	Describe("Prices of products", func() {
+		var stub *testStub
+
+		BeforeEach(func() {
+			stub = newTestStub()
+			stub.Init("000", reviewConfig)
+			registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+			putReferencedAssets(stub)
+		})
+
+		It("Should keep the price as entered and store the currency code and the amount", func() {
+			args := addProductArgs(productUUID, "")
+			args[7] = `[{"lang": "de", "name": "Ovomaltine", "price": "4,99", "currency": "€"}, {"lang": "fr", "name": "Ovomaltine", "currency": "chf"}, {"lang": "it", "name": "Ovomaltine"}]`
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(int32(200)), response.Message)
+
+			var product viridian.Product
+			Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+			Expect(product.Locales[0].Price).To(Equal("4,99"))
+			Expect(product.Locales[0].Currency).To(Equal("EUR"))
+			Expect(product.Locales[0].PriceAmount).To(Equal(&money.Amount{Value: 499, Currency: "EUR"}))
+			Expect(product.Locales[1].Currency).To(Equal("CHF"))
+			Expect(product.Locales[1].PriceAmount).To(BeNil())
+			Expect(product.Locales[2].PriceAmount).To(BeNil())
+		})
+
+		It("Should require a currency for a price", func() {
+			args := addProductArgs(productUUID, "")
+			args[7] = `[{"lang": "de", "name": "Ovomaltine", "price": "4.99"}]`
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(int32(500)))
+			Expect(response.Message).To(ContainSubstring(`{"field":"locales[0].currency","value":"","problem":"MISSING"}`))
+		})
+
+		It("Should ignore a price amount sent by the client", func() {
+			args := addProductArgs(productUUID, "")
+			args[7] = `[{"lang": "de", "name": "Ovomaltine", "priceAmount": {"value": 1, "currency": "EUR"}}]`
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(int32(200)), response.Message)
+
+			var product viridian.Product
+			Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+			Expect(product.Locales[0].PriceAmount).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,105 @@
+package viridian_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+var _ = Describe("Pagination", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	page := func(args ...string) viridian.QueryPage {
+		response := stub.Invoke("query", args...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		var page viridian.QueryPage
+		Expect(json.Unmarshal(response.Payload, &page)).To(Succeed())
+		Expect(page.FetchedRecordsCount).To(BeNumerically("==", len(page.Records)))
+		return page
+	}
+
+	// allPages follows the bookmarks until a page is not full and returns the
+	// keys of all records
+	allPages := func(pageSize int, args ...string) []string {
+		var keys []string
+		bookmark := ""
+		for {
+			p := page(append(args, fmt.Sprint(pageSize), bookmark)...)
+			for _, record := range p.Records {
+				keys = append(keys, record.Key)
+			}
+			if len(p.Records) < pageSize {
+				return keys
+			}
+			bookmark = p.Bookmark
+		}
+	}
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		for i := 0; i < 5; i++ {
+			response := stub.Invoke(fmt.Sprintf("label-%d", i), "addLabel", fmt.Sprintf("%d5b1c3f2-0000-4000-8000-000000000000", i),
+				`[{"lang": "de", "name": "Bio"}]`, "")
+			Expect(response.Status).Should(Equal(status200), response.Message)
+		}
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	It("Should page through the results of a rich query", func() {
+		first := page("queryLabelsByNameWithPagination", "Bio", "de", "", "2", "")
+		Expect(first.Records).To(HaveLen(2))
+		Expect(first.Bookmark).NotTo(BeEmpty())
+		var label viridian.Label
+		Expect(json.Unmarshal(first.Records[0].Value, &label)).To(Succeed())
+		Expect(label.Locales[0].Name).To(Equal("Bio"))
+
+		keys := allPages(2, "queryLabelsByNameWithPagination", "bi", "", "PREFIX")
+		Expect(keys).To(HaveLen(5))
+		Expect(keys[0]).To(Equal(first.Records[0].Key))
+		Expect(keys[4]).NotTo(Equal(keys[3]))
+	})
+
+	It("Should page through products by GTIN and name", func() {
+		Expect(allPages(1, "queryProductsByGTINWithPagination", "7612100055557")).To(Equal([]string{productKey}))
+		Expect(allPages(1, "queryProductsByNameWithPagination", "Unknown", "", "")).To(BeEmpty())
+	})
+
+	It("Should page through the assets of one type by key range", func() {
+		keys := allPages(3, "getLabelsByRangeWithPagination", "", "")
+		Expect(keys).To(HaveLen(6)) // five labels added and the fixture
+		for _, key := range keys {
+			Expect(key).To(HavePrefix("label-"))
+		}
+		Expect(allPages(3, "getProductsByRangeWithPagination", "", "")).To(Equal([]string{productKey}))
+		Expect(allPages(3, "getProducersByRangeWithPagination", "", "")).To(Equal([]string{producerKey}))
+		Expect(allPages(3, "getLabelsByRangeWithPagination", "label-1", "label-3")).To(HaveLen(2))
+	})
+
+	It("Should reject invalid arguments", func() {
+		response := stub.Invoke("002", "queryLabelsByNameWithPagination", "Bio", "de", "", "0", "")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("pageSize"))
+
+		response = stub.Invoke("003", "getLabelsByRangeWithPagination", "product-", "", "10", "")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("keys of labels"))
+
+		// would include the keys of other docTypes starting with "label", e.g. "labelCategory-..."
+		response = stub.Invoke("004", "getLabelsByRangeWithPagination", "", "labels", "10", "")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("keys of labels"))
+
+		response = stub.Invoke("005", "queryProductsByGTINWithPagination", "7612100055557", "10")
+		Expect(response.Status).Should(Equal(status500))
+	})
+})
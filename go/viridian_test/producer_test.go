@@ -0,0 +1,92 @@
+package viridian_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+const (
+	wanderUUID    = "e0c2ad4e-7c32-4d5b-9d43-6a1f9b2c8a77"
+	wanderKey     = "producer-" + wanderUUID
+	wanderLocales = `[{"lang": "de", "name": "Wander AG", "address": "CH-3176 Neuenegg, Switzerland", "urls": ["https://www.wander.ch/"]}, {"lang": "fr", "name": "Wander SA"}]`
+)
+
+var _ = Describe("Producer", func() {
+	var stub *testStub
+	status200 := int32(200)
+
+	// keys returns the keys of the producers in a query result
+	keys := func(response []byte) []string {
+		var records []viridian.QueryRecord
+		Expect(json.Unmarshal(response, &records)).To(Succeed())
+		keys := []string{}
+		for _, record := range records {
+			keys = append(keys, record.Key)
+		}
+		return keys
+	}
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		response := stub.Invoke("001", "initProducer", wanderUUID, wanderLocales, "[]")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	It("Should add a preliminary producer with its locales", func() {
+		var producer viridian.Producer
+		Expect(stub.GetFixture(wanderKey, &producer)).To(BeTrue())
+		Expect(producer.Status).To(Equal(viridian.Preliminary))
+		Expect(producer.Locales).To(HaveLen(2))
+		Expect(producer.Locales[0].Address).To(Equal("CH-3176 Neuenegg, Switzerland"))
+		Expect(producer.Locales[1].Name).To(Equal("Wander SA"))
+	})
+
+	It("Should find producers by the name of a locale", func() {
+		response := stub.Invoke("002", "queryProducersByName", "Wander SA", "fr")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		Expect(keys(response.Payload)).To(Equal([]string{wanderKey}))
+
+		response = stub.Invoke("003", "queryProducersByName", "Wander SA", "de")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		Expect(keys(response.Payload)).To(BeEmpty())
+	})
+
+	Describe("Producers stored with a name, address and URL", func() {
+		const oldKey = "producer-84a234b7-c9d8-43b2-93c9-90f83d8773fb"
+
+		BeforeEach(func() {
+			stub.PutFixture(oldKey, json.RawMessage(`{"docType": "producer", "name": "Migros",
+				"address": "CH-8005 Zürich", "url": "https://www.migros.ch/", "labels": [], "status": 2}`))
+		})
+
+		It("Should be read with one locale", func() {
+			response := stub.Invoke("002", "readProducer", oldKey)
+			Expect(response.Status).Should(Equal(status200), response.Message)
+			var doc map[string]interface{}
+			Expect(json.Unmarshal(response.Payload, &doc)).To(Succeed())
+			Expect(doc).NotTo(HaveKey("name"))
+
+			var producer viridian.Producer
+			Expect(json.Unmarshal(response.Payload, &producer)).To(Succeed())
+			Expect(producer.Locales).To(Equal([]viridian.ProducerLocaleData{
+				{Name: "Migros", Address: "CH-8005 Zürich", URLs: []string{"https://www.migros.ch/"}},
+			}))
+		})
+
+		It("Should be found by name unless a language is given", func() {
+			response := stub.Invoke("002", "queryProducersByName", "mig", "", "PREFIX")
+			Expect(response.Status).Should(Equal(status200), response.Message)
+			Expect(keys(response.Payload)).To(Equal([]string{oldKey}))
+
+			response = stub.Invoke("003", "queryProducersByName", "Migros", "de")
+			Expect(response.Status).Should(Equal(status200), response.Message)
+			Expect(keys(response.Payload)).To(BeEmpty())
+		})
+	})
+})
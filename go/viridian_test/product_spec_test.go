@@ -0,0 +1,332 @@
+package viridian_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+// The specs in this file follow the product specification in specs.md item
+// by item: every input, side effect and edge case listed there has a spec.
+// The ones the chaincode does not implement yet are pending.
+
+// Positions of the arguments shared by addProduct and editProduct
+const (
+	argKey = iota
+	argGTIN
+	argProducers
+	argContainedProducts
+	argProductCategories
+	argLabels
+	argLocales
+)
+
+// usedUUID is the key of a product that is already stored when an edge case runs
+const usedUUID = "d1b7f3ee-5d9f-4bd6-9c5e-0a4e0f1e2c11"
+
+// productArgsChange changes the arguments shared by addProduct and
+// editProduct (see the arg* constants), or the stub they run on, so that
+// the transaction runs into an edge case
+type productArgsChange func(stub *testStub, args []string)
+
+func withArg(i int, value string) productArgsChange {
+	return func(stub *testStub, args []string) {
+		args[i] = value
+	}
+}
+
+func withLocales(locales string) productArgsChange {
+	return withArg(argLocales, locales)
+}
+
+func withCreator(name string) productArgsChange {
+	return func(stub *testStub, args []string) {
+		stub.SetCreator(name)
+	}
+}
+
+// productInputEdgeCases are the edge cases specs.md lists for addProduct,
+// which editProduct shares for the new version
+var productInputEdgeCases = []TableEntry{
+	Entry("No product key provided", withArg(argKey, ""), "Product key not provided"),
+	Entry("Product key already used", withArg(argKey, usedUUID), "already exists"),
+	Entry("Producer key not found in blockchain", withArg(argProducers, `["producer-does-not-exist"]`), `"field":"producers[0]"`),
+	Entry("Contained product keys not found in blockchain", withArg(argContainedProducts, `["product-does-not-exist"]`), `"field":"containedProducts[0]"`),
+	Entry("Product categories not a list", withArg(argProductCategories, "productCategory-spreads"), "'productCategories' must be a string with a JSON list"),
+	Entry("Label keys not found in blockchain", withArg(argLabels, `["label-does-not-exist"]`), `"field":"labels[0]"`),
+	Entry("Not even one locale", withLocales(`[]`), `{"field":"locales","value":"","problem":"MISSING"}`),
+	Entry("More than one locale with same lang", withLocales(`[{"lang": "de", "name": "Ovomaltine"}, {"lang": "de", "name": "Ovomaltine crunchy"}]`), `{"field":"locales[1].lang","value":"de","problem":"DUPLICATE"}`),
+	Entry("Locale without name", withLocales(`[{"lang": "de"}]`), `{"field":"locales[0].name","value":"","problem":"MISSING"}`),
+	Entry("Invalid GTIN", withArg(argGTIN, "7612100055558"), "Invalid GTIN"),
+	Entry("Invalid lang", withLocales(`[{"lang": "german", "name": "Ovomaltine"}]`), `{"field":"locales[0].lang","value":"german","problem":"MALFORMED"}`),
+	Entry("Invalid price", withLocales(`[{"lang": "de", "name": "Ovomaltine", "price": "cheap", "currency": "EUR"}]`), `{"field":"locales[0].price","value":"cheap","problem":"MALFORMED","message":"price \"cheap\" is malformed: no digits"}`),
+	Entry("Invalid currency", withLocales(`[{"lang": "de", "name": "Ovomaltine", "price": "4.99", "currency": "Euro"}]`), `{"field":"locales[0].currency","value":"Euro","problem":"UNKNOWN_CURRENCY"}`),
+	Entry("Invalid URL", withLocales(`[{"lang": "de", "name": "Ovomaltine", "urls": ["javascript:alert(1)"]}]`), `{"field":"locales[0].urls[0]","value":"javascript:alert(1)","problem":"UNSUPPORTED_SCHEME"`),
+	Entry("Submitting user not registered", withCreator("mallory"), "not registered"),
+}
+
+var _ = Describe("Product specification", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		stub.PutFixture("product-"+usedUUID, &viridian.Product{DocType: "product",
+			ScorableAsset: viridian.ScorableAsset{UpdatableAsset: viridian.UpdatableAsset{ReviewableAsset: viridian.ReviewableAsset{Status: viridian.Active}}}})
+	})
+
+	// product reads the product stored under key
+	product := func(key string) viridian.Product {
+		var p viridian.Product
+		Expect(stub.GetFixture(key, &p)).To(BeTrue(), key)
+		return p
+	}
+
+	// addActiveProduct adds the product of addProductArgs as user1 and lets
+	// its review pass
+	addActiveProduct := func() {
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		decide(stub, productKey, "APPROVED", 2)
+		Expect(openReviews(stub, productKey)).To(BeEmpty())
+		stub.SetCreator("user2")
+	}
+
+	Describe("addProduct", func() {
+		Describe("Results/Side Effects", func() {
+			BeforeEach(func() {
+				response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+			})
+
+			It("Should register a new product under the key with status Preliminary", func() {
+				p := product(productKey)
+				Expect(p.Status).To(Equal(viridian.Preliminary))
+				Expect(p.Locales).To(HaveLen(1))
+				Expect(p.Locales[0].Name).To(Equal("Ovomaltine crunchy cream - 400 g"))
+			})
+
+			It("Should create a review with random users assigned to it", func() {
+				reviews := openReviews(stub, productKey)
+				Expect(reviews).To(HaveLen(3))
+				Expect(reviews).NotTo(HaveKey("user1"))
+			})
+
+			It("Should give the product status Active if the review passed", func() {
+				decide(stub, productKey, "APPROVED", 2)
+				Expect(product(productKey).Status).To(Equal(viridian.Active))
+			})
+
+			It("Should give the product status Rejected if the review did not pass", func() {
+				decide(stub, productKey, "REJECTED", 2)
+				Expect(product(productKey).Status).To(Equal(viridian.Rejected))
+			})
+
+			PIt("Should store similar existing products as possible duplicates with the review")
+
+			PIt("Should display similar products to the user during the add process")
+		})
+
+		DescribeTable("Edge cases",
+			func(change productArgsChange, message string) {
+				args := addProductArgs(productUUID, "7612100055557")
+				change(stub, args[1:])
+				response := stub.Invoke("001", args...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring(message))
+				Expect(stub.GetFixture(productKey, new(viridian.Product))).To(BeFalse())
+			},
+			productInputEdgeCases...,
+		)
+	})
+
+	Describe("editProduct", func() {
+		BeforeEach(addActiveProduct)
+
+		Describe("Results/Side Effects", func() {
+			BeforeEach(func() {
+				response := stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+			})
+
+			It("Should register a new product under the new key superseding the old one", func() {
+				Expect(product(editKey).Supersedes).To(Equal(productKey))
+				Expect(product(editKey).ChangeReason).To(Equal("Wrong quantity information."))
+			})
+
+			It("Should store the new key under supersededBy of the old product", func() {
+				Expect(product(productKey).SupersededBy).To(Equal(editKey))
+			})
+
+			It("Should create a review with random users assigned to it", func() {
+				reviews := openReviews(stub, editKey)
+				Expect(reviews).To(HaveLen(3))
+				Expect(reviews).NotTo(HaveKey("user2"))
+			})
+
+			It("Should keep the new product Preliminary and the old one Active until the review is closed", func() {
+				Expect(product(editKey).Status).To(Equal(viridian.Preliminary))
+				Expect(product(productKey).Status).To(Equal(viridian.Active))
+			})
+
+			It("Should make the old product Outdated and the new one Active if the review passed", func() {
+				decide(stub, editKey, "APPROVED", 2)
+				Expect(product(productKey).Status).To(Equal(viridian.Outdated))
+				Expect(product(editKey).Status).To(Equal(viridian.Active))
+			})
+
+			It("Should keep the old product Active, reject the new one and clear supersededBy if the review did not pass", func() {
+				decide(stub, editKey, "REJECTED", 2)
+				Expect(product(productKey).Status).To(Equal(viridian.Active))
+				Expect(product(productKey).SupersededBy).To(BeEmpty())
+				Expect(product(editKey).Status).To(Equal(viridian.Rejected))
+			})
+
+			PIt("Should store similar existing products as possible duplicates with the review")
+
+			PIt("Should display similar products to the user during the edit process")
+		})
+
+		Describe("Edge cases", func() {
+			It("Should reject an old product key that is not found", func() {
+				response := stub.Invoke("002", editProductArgs("product-does-not-exist", "Wrong quantity information.", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("does not exist"))
+			})
+
+			DescribeTable("Should reject an old product that does not have status Active",
+				func(status viridian.Status) {
+					setStatus(stub, productKey, status)
+					response := stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+					Expect(response.Status).Should(Equal(status500))
+					Expect(response.Message).To(ContainSubstring("not active"))
+				},
+				Entry("Preliminary", viridian.Preliminary),
+				Entry("Outdated", viridian.Outdated),
+				Entry("Deleted", viridian.Deleted),
+				Entry("Rejected", viridian.Rejected),
+			)
+
+			DescribeTable("Should reject an old product whose supersededBy is not empty",
+				func(pending []string) {
+					response := stub.Invoke("002", pending...)
+					Expect(response.Status).Should(Equal(status200), response.Message)
+					response = stub.Invoke("003", editProductArgs(productKey, "Wrong price.", "5b0e6c1a-2f4d-4c8e-9a7b-3d2e1f0a9c86", "7612100055557")...)
+					Expect(response.Status).Should(Equal(status500))
+					Expect(response.Message).To(ContainSubstring("pending"))
+				},
+				Entry("edit pending", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")),
+				Entry("deletion pending", []string{"deleteProduct", productKey, "This product does not exist."}),
+			)
+
+			It("Should require a change reason", func() {
+				response := stub.Invoke("002", editProductArgs(productKey, "", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("Change reason not provided"))
+			})
+
+			DescribeTable("Should check the new version like addProduct",
+				func(change productArgsChange, message string) {
+					args := editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")
+					change(stub, args[3:])
+					response := stub.Invoke("002", args...)
+					Expect(response.Status).Should(Equal(status500))
+					Expect(response.Message).To(ContainSubstring(message))
+					Expect(product(productKey).SupersededBy).To(BeEmpty())
+				},
+				productInputEdgeCases...,
+			)
+		})
+	})
+
+	Describe("deleteProduct", func() {
+		BeforeEach(addActiveProduct)
+
+		Describe("Results/Side Effects", func() {
+			BeforeEach(func() {
+				response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+				Expect(response.Status).Should(Equal(status200), response.Message)
+			})
+
+			It("Should create a review with random users assigned to it", func() {
+				reviews := openReviews(stub, productKey)
+				Expect(reviews).NotTo(BeEmpty())
+				Expect(reviews).NotTo(HaveKey("user2"))
+			})
+
+			It("Should keep the product Active until the review is closed", func() {
+				Expect(product(productKey).Status).To(Equal(viridian.Active))
+			})
+
+			It("Should set supersededBy of the product to DELETION", func() {
+				Expect(product(productKey).SupersededBy).To(Equal("DELETION"))
+				Expect(product(productKey).ChangeReason).To(BeEmpty())
+			})
+
+			It("Should give the product status Deleted if the review passed", func() {
+				decide(stub, productKey, "APPROVED", 2)
+				Expect(product(productKey).Status).To(Equal(viridian.Deleted))
+				Expect(product(productKey).ChangeReason).To(Equal("This product does not exist."))
+			})
+
+			It("Should keep the product Active and clear supersededBy if the review did not pass", func() {
+				decide(stub, productKey, "REJECTED", 2)
+				Expect(product(productKey).Status).To(Equal(viridian.Active))
+				Expect(product(productKey).SupersededBy).To(BeEmpty())
+			})
+		})
+
+		Describe("Edge cases", func() {
+			It("Should reject a product key that is not found", func() {
+				response := stub.Invoke("002", "deleteProduct", "product-does-not-exist", "This product does not exist.")
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("does not exist"))
+			})
+
+			DescribeTable("Should reject a product that does not have status Active",
+				func(status viridian.Status) {
+					setStatus(stub, productKey, status)
+					response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+					Expect(response.Status).Should(Equal(status500))
+					Expect(response.Message).To(ContainSubstring("not active"))
+				},
+				Entry("Preliminary", viridian.Preliminary),
+				Entry("Outdated", viridian.Outdated),
+				Entry("Deleted", viridian.Deleted),
+				Entry("Rejected", viridian.Rejected),
+			)
+
+			DescribeTable("Should reject a product whose supersededBy is not empty",
+				func(pending []string) {
+					response := stub.Invoke("002", pending...)
+					Expect(response.Status).Should(Equal(status200), response.Message)
+					response = stub.Invoke("003", "deleteProduct", productKey, "Duplicate.")
+					Expect(response.Status).Should(Equal(status500))
+					Expect(response.Message).To(ContainSubstring("pending"))
+				},
+				Entry("edit pending", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")),
+				Entry("deletion pending", []string{"deleteProduct", productKey, "This product does not exist."}),
+			)
+
+			It("Should require a change reason", func() {
+				response := stub.Invoke("002", "deleteProduct", productKey, "")
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("Change reason not provided"))
+			})
+
+			It("Should reject a submitting user who is not registered", func() {
+				stub.SetCreator("mallory")
+				response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("not registered"))
+				Expect(product(productKey).SupersededBy).To(BeEmpty())
+			})
+		})
+	})
+})
@@ -1,37 +1,566 @@
 package viridian_test
 
 import (
+	"encoding/json"
 	"fmt"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
 	"github.com/chaincode/viridian/go/viridian"
 )
 
+const (
+	productUUID = "1fcc2c43-12a1-4451-ac56-dd73099b3f34"
+	productKey  = "product-" + productUUID
+	editUUID    = "3c6aa2a8-0a8b-4b5e-b4a4-1ab5c43f0a39"
+	editKey     = "product-" + editUUID
+	producerKey = "producer-84a234b7-c9d8-43b2-93c9-90f83d8773fb"
+	labelKey    = "label-31d3a05e-fb10-483c-8c8b-0c7079e5bc95"
+)
+
+func addProductArgs(key string, gtin string) []string {
+	return []string{
+		"addProduct",
+		key,                         // key
+		gtin,                        // GTIN
+		"[\"" + producerKey + "\"]", // producer keys
+		"[]",                        // contained product keys
+		"[]",                        // product category keys
+		"[\"" + labelKey + "\"]",    // label keys
+		"[{\"lang\": \"de\", \"name\": \"Ovomaltine crunchy cream - 400 g\",\"price\": \"4.99\",\"currency\": \"EUR\",\"description\": \"Brotaufstrich mit malzhaltigem Getraenkepulver Ovomaltine\",\"quantities\": [\"400 g\"]}]", // locales
+	}
+}
+
+func editProductArgs(oldKey string, changeReason string, key string, gtin string) []string {
+	return append([]string{"editProduct", oldKey, changeReason}, addProductArgs(key, gtin)[1:]...)
+}
+
+// putReferencedAssets stores the active producer and label referenced by
+// addProductArgs
+func putReferencedAssets(stub *testStub) {
+	stub.PutFixture(producerKey, &viridian.Producer{DocType: "producer", Locales: []viridian.ProducerLocaleData{{Lang: "de", Name: "Wander AG"}},
+		ScorableAsset: viridian.ScorableAsset{UpdatableAsset: viridian.UpdatableAsset{ReviewableAsset: viridian.ReviewableAsset{Status: viridian.Active}}}})
+	stub.PutFixture(labelKey, &viridian.Label{DocType: "label",
+		ScorableAsset: viridian.ScorableAsset{UpdatableAsset: viridian.UpdatableAsset{ReviewableAsset: viridian.ReviewableAsset{Status: viridian.Active}}}})
+}
+
+// setStatus overwrites the status of a stored product, standing in for a
+// closed review
+func setStatus(stub *testStub, key string, status viridian.Status) {
+	var product viridian.Product
+	Expect(stub.GetFixture(key, &product)).To(BeTrue())
+	product.Status = status
+	stub.PutFixture(key, &product)
+}
+
 var _ = Describe("Product", func() {
-	stub := shim.NewMockStub("testingStub", new(viridian.Chaincode))
+	var stub *testStub
 	status200 := int32(200)
+	status500 := int32(500)
 
-	BeforeSuite(func() {
-		stub.MockInit("000", nil)
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
 	})
 
 	Describe("Checking product lifecycle", func() {
 		It("Should be possible to add a new product", func() {
-			args := [][]byte{
-				[]byte("addProduct"),
-				[]byte("1fcc2c43-12a1-4451-ac56-dd73099b3f34"),          // key
-				[]byte("7612100055557"),                                 // GTIN
-				[]byte("producer-84a234b7-c9d8-43b2-93c9-90f83d8773fb"), // producer key
-				[]byte("[]"), // contained product keys
-				[]byte("[\"label-31d3a05e-fb10-483c-8c8b-0c7079e5bc95\"]"), // label keys
-				[]byte("[{\"lang\": \"de\", \"name\": \"Ovomaltine crunchy cream - 400 g\",\"price\": \"4.99\",\"currency\": \"EUR\",\"description\": \"Brotaufstrich mit malzhaltigem Getraenkepulver Ovomaltine\",\"quantities\": [\"400 g\"]}]"), // locales
-			}
-			response := stub.MockInvoke("000", args)
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
 			fmt.Println(response)
 			Expect(response.Status).Should(Equal(status200))
+
+			var product viridian.Product
+			Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+			Expect(product.Status).To(Equal(viridian.Preliminary))
+			Expect(product.GTIN).To(Equal("07612100055557"))
+		})
+
+		It("Should not be possible to add two products with the same GTIN", func() {
+			stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			response := stub.Invoke("002", addProductArgs(editUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("GTIN already exists"))
+		})
+
+		It("Should treat zero-padded forms of a GTIN as the same product", func() {
+			stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			response := stub.Invoke("002", addProductArgs(editUUID, "07612100055557")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("GTIN already exists"))
+		})
+
+		It("Should reject an invalid GTIN", func() {
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055558")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("check digit"))
+		})
+
+		It("Should find a product by any zero-padded form of its GTIN", func() {
+			stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			for _, gtin := range []string{"7612100055557", "07612100055557"} {
+				response := stub.Invoke("002", "queryProductsByGTIN", gtin)
+				Expect(response.Status).Should(Equal(status200))
+				Expect(string(response.Payload)).To(ContainSubstring(productKey))
+			}
+		})
+
+		It("Should check GTIN uniqueness without rich queries", func() {
+			stub.LevelDB = true
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+			response = stub.Invoke("002", addProductArgs(editUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("GTIN already exists"))
+		})
+
+		It("Should free the GTIN of a rejected product", func() {
+			stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			decide(stub, productKey, "REJECTED", 2)
+			stub.SetCreator("user1")
+			response := stub.Invoke("002", addProductArgs(editUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+		})
+
+		It("Should keep the GTIN taken after an approved edit", func() {
+			stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			decide(stub, productKey, "APPROVED", 2)
+			stub.SetCreator("user2")
+			stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+			decide(stub, editKey, "APPROVED", 2)
+			Expect(productStatus(stub, productKey)).To(Equal(viridian.Outdated))
+
+			stub.SetCreator("user1")
+			response := stub.Invoke("003", addProductArgs("d1b7f3ee-5d9f-4bd6-9c5e-0a4e0f1e2c11", "7612100055557")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("GTIN already exists"))
+		})
+
+		Describe("Products stored before GTINs were normalized and indexed", func() {
+			BeforeEach(func() {
+				stub.PutFixture(productKey, json.RawMessage(fmt.Sprintf(`{"docType": "product", "gtin": "7612100055557",
+					"producer": "%s", "containedProducts": [], "labels": [], "status": %d,
+					"locales": [{"lang": "de", "name": "Ovomaltine"}]}`, producerKey, viridian.Active)))
+				response := stub.Init("002", reviewConfig)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+			})
+
+			It("Should keep their GTIN taken after an upgrade", func() {
+				response := stub.Invoke("003", addProductArgs(editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("GTIN already exists"))
+			})
+
+			It("Should be stored with their GTIN-14 after an upgrade", func() {
+				var product viridian.Product
+				Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+				Expect(product.GTIN).To(Equal("07612100055557"))
+				Expect(product.Producers).To(Equal([]string{producerKey}))
+			})
+
+			It("Should be found by GTIN after an upgrade", func() {
+				response := stub.Invoke("003", "queryProductsByGTIN", "7612100055557")
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				var records []viridian.QueryRecord
+				Expect(json.Unmarshal(response.Payload, &records)).To(Succeed())
+				Expect(records).To(HaveLen(1))
+				Expect(records[0].Key).To(Equal(productKey))
+
+				response = stub.Invoke("004", "queryProductsByGTINWithPagination", "7612100055557", "10", "")
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				var page viridian.QueryPage
+				Expect(json.Unmarshal(response.Payload, &page)).To(Succeed())
+				Expect(page.Records).To(HaveLen(1))
+				Expect(page.Records[0].Key).To(Equal(productKey))
+			})
+
+			It("Should not be indexed twice by another upgrade", func() {
+				entries := len(stub.State)
+				response := stub.Init("003", reviewConfig)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				Expect(stub.State).To(HaveLen(entries))
+			})
+		})
+	})
+
+	Describe("Editing a product", func() {
+		BeforeEach(func() {
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+		})
+
+		Context("when the product is active", func() {
+			BeforeEach(func() {
+				setStatus(stub, productKey, viridian.Active)
+			})
+
+			It("Should create a new preliminary version superseding the old one", func() {
+				stub.SetCreator("user2")
+				response := stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status200))
+
+				var oldProduct, newProduct viridian.Product
+				Expect(stub.GetFixture(productKey, &oldProduct)).To(BeTrue())
+				Expect(stub.GetFixture(editKey, &newProduct)).To(BeTrue())
+				Expect(oldProduct.Status).To(Equal(viridian.Active))
+				Expect(oldProduct.SupersededBy).To(Equal(editKey))
+				Expect(newProduct.Status).To(Equal(viridian.Preliminary))
+				Expect(newProduct.Supersedes).To(Equal(productKey))
+				Expect(newProduct.ChangeReason).To(Equal("Wrong quantity information."))
+				Expect(newProduct.CreatedBy).To(Equal(oldProduct.CreatedBy))
+				Expect(newProduct.UpdatedBy).NotTo(Equal(oldProduct.CreatedBy))
+			})
+
+			It("Should reject a second edit while the first one is pending", func() {
+				response := stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status200))
+				response = stub.Invoke("003", editProductArgs(productKey, "Wrong price.", "d1b7f3ee-5d9f-4bd6-9c5e-0a4e0f1e2c11", "7612100055557")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("pending"))
+			})
+
+			It("Should reject a new key that is already in use", func() {
+				stub.Invoke("002", addProductArgs(editUUID, "")...)
+				response := stub.Invoke("003", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("already exists"))
+			})
+
+			It("Should reject a GTIN used by another product", func() {
+				stub.Invoke("002", addProductArgs("d1b7f3ee-5d9f-4bd6-9c5e-0a4e0f1e2c11", "4000417025005")...)
+				response := stub.Invoke("003", editProductArgs(productKey, "Wrong barcode.", editUUID, "4000417025005")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("GTIN already exists"))
+			})
+
+			It("Should require a change reason", func() {
+				response := stub.Invoke("002", editProductArgs(productKey, "", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("Change reason"))
+			})
+		})
+
+		It("Should reject an old key that does not exist", func() {
+			response := stub.Invoke("002", editProductArgs("product-does-not-exist", "Wrong quantity information.", editUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("does not exist"))
+		})
+
+		It("Should reject an old product that is not active", func() {
+			response := stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("not active"))
+		})
+	})
+
+	Describe("Querying products by name", func() {
+		BeforeEach(func() {
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+		})
+
+		DescribeTable("Should find products with more than name and lang in their locale",
+			func(found bool, args ...string) {
+				response := stub.Invoke("002", append([]string{"queryProductsByName"}, args...)...)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				if found {
+					Expect(string(response.Payload)).To(ContainSubstring(productKey))
+				} else {
+					Expect(string(response.Payload)).To(Equal("[]"))
+				}
+			},
+			Entry("by exact name", true, "Ovomaltine crunchy cream - 400 g"),
+			Entry("by exact name and lang", true, "Ovomaltine crunchy cream - 400 g", "de"),
+			Entry("by exact name and empty lang", true, "Ovomaltine crunchy cream - 400 g", ""),
+			Entry("not by exact name in another lang", false, "Ovomaltine crunchy cream - 400 g", "fr"),
+			Entry("not by a part of the name", false, "Ovomaltine"),
+			Entry("by prefix, ignoring case", true, "ovomaltine CRUNCHY", "", "PREFIX"),
+			Entry("by prefix and lang", true, "Ovo", "de", "PREFIX"),
+			Entry("not by a prefix in another lang", false, "Ovo", "fr", "PREFIX"),
+			Entry("not by a substring as prefix", false, "crunchy", "", "PREFIX"),
+			Entry("by substring, ignoring case", true, "CRUNCHY", "", "SUBSTRING"),
+			Entry("by exact match", true, "Ovomaltine crunchy cream - 400 g", "", "EXACT"),
+		)
+
+		It("Should reject an unknown match mode", func() {
+			response := stub.Invoke("002", "queryProductsByName", "Ovo", "", "FUZZY")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("'match'"))
+		})
+	})
+
+	Describe("Checking references", func() {
+		It("Should list every dangling reference", func() {
+			args := addProductArgs(productUUID, "7612100055557")
+			args[3] = "[\"producer-does-not-exist\"]"
+			args[4] = "[\"product-does-not-exist\"]"
+			args[6] = "[\"" + labelKey + "\", \"label-does-not-exist\"]"
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("3 dangling reference(s)"))
+			Expect(response.Message).To(ContainSubstring(`{"field":"producers[0]","key":"producer-does-not-exist","docType":"producer","problem":"NOT_FOUND"}`))
+			Expect(response.Message).To(ContainSubstring(`{"field":"containedProducts[0]","key":"product-does-not-exist","docType":"product","problem":"NOT_FOUND"}`))
+			Expect(response.Message).To(ContainSubstring(`{"field":"labels[1]","key":"label-does-not-exist","docType":"label","problem":"NOT_FOUND"}`))
+			Expect(response.Message).NotTo(ContainSubstring(`"labels[0]"`))
+		})
+
+		It("Should reject a reference to an asset of another type", func() {
+			args := addProductArgs(productUUID, "7612100055557")
+			args[3] = "[\"" + labelKey + "\"]"
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring(`"problem":"WRONG_DOCTYPE"`))
+		})
+
+		It("Should reject references to rejected and deleted assets", func() {
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+			setStatus(stub, productKey, viridian.Deleted)
+			var label viridian.Label
+			Expect(stub.GetFixture(labelKey, &label)).To(BeTrue())
+			label.Status = viridian.Rejected
+			stub.PutFixture(labelKey, &label)
+
+			args := addProductArgs(editUUID, "")
+			args[4] = "[\"" + productKey + "\"]"
+			response = stub.Invoke("002", args...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring(`"key":"` + productKey + `","docType":"product","problem":"DELETED"`))
+			Expect(response.Message).To(ContainSubstring(`"key":"` + labelKey + `","docType":"label","problem":"REJECTED"`))
+		})
+
+		It("Should accept references to preliminary assets", func() {
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+			args := addProductArgs(editUUID, "")
+			args[4] = "[\"" + productKey + "\"]"
+			response = stub.Invoke("002", args...)
+			Expect(response.Status).Should(Equal(status200), response.Message)
+		})
+
+		It("Should check the references of an edit", func() {
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+			setStatus(stub, productKey, viridian.Active)
+			args := editProductArgs(productKey, "Wrong producer.", editUUID, "7612100055557")
+			args[5] = "[\"producer-does-not-exist\"]"
+			response = stub.Invoke("002", args...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring(`"field":"producers[0]"`))
+		})
+
+		It("Should check the labels of a producer", func() {
+			response := stub.Invoke("001", "initProducer", wanderUUID, wanderLocales, "[\"label-does-not-exist\"]")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring(`"field":"labels[0]"`))
+		})
+	})
+
+	Describe("Deleting a product", func() {
+		BeforeEach(func() {
+			response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+		})
+
+		Context("when the product is active", func() {
+			BeforeEach(func() {
+				setStatus(stub, productKey, viridian.Active)
+			})
+
+			It("Should mark the product for deletion and keep it unchanged otherwise", func() {
+				var activeProduct viridian.Product
+				Expect(stub.GetFixture(productKey, &activeProduct)).To(BeTrue())
+				response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+				Expect(response.Status).Should(Equal(status200))
+
+				var product viridian.Product
+				Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+				Expect(product.Status).To(Equal(viridian.Active))
+				Expect(product.SupersededBy).To(Equal(viridian.DeletionRequest))
+				Expect(product.UpdatedBy).To(Equal(activeProduct.UpdatedBy))
+				Expect(product.UpdatedAt).To(Equal(activeProduct.UpdatedAt))
+				Expect(product.ChangeReason).To(Equal(activeProduct.ChangeReason))
+			})
+
+			It("Should reject a second deletion request while the first one is pending", func() {
+				stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+				response := stub.Invoke("003", "deleteProduct", productKey, "Duplicate.")
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("pending"))
+			})
+
+			It("Should reject a deletion request while an edit is pending", func() {
+				stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+				response := stub.Invoke("003", "deleteProduct", productKey, "This product does not exist.")
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("pending"))
+			})
+
+			It("Should reject an edit while a deletion request is pending", func() {
+				stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+				response := stub.Invoke("003", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("pending"))
+			})
+
+			It("Should require a change reason", func() {
+				response := stub.Invoke("002", "deleteProduct", productKey, "")
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("Change reason"))
+			})
+		})
+
+		It("Should reject a key that does not exist", func() {
+			response := stub.Invoke("002", "deleteProduct", "product-does-not-exist", "This product does not exist.")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("does not exist"))
+		})
+
+		It("Should reject a product that is not active", func() {
+			response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("not active"))
+		})
+
+		It("Should reject a product that is already deleted", func() {
+			setStatus(stub, productKey, viridian.Deleted)
+			response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("not active"))
+		})
+	})
+
+	Describe("Producers and product categories", func() {
+		const otherProducerKey = "producer-5b0e8f3c-2d7a-4e19-9c6b-8f1a2d3e4b5c"
+
+		// keys returns the keys of the products in a query result
+		keys := func(response []byte) []string {
+			var records []viridian.QueryRecord
+			Expect(json.Unmarshal(response, &records)).To(Succeed())
+			keys := []string{}
+			for _, record := range records {
+				keys = append(keys, record.Key)
+			}
+			return keys
+		}
+
+		It("Should store several producers and the product categories", func() {
+			stub.PutFixture(otherProducerKey, &viridian.Producer{DocType: "producer", Locales: []viridian.ProducerLocaleData{{Lang: "de", Name: "Migros"}},
+				ScorableAsset: viridian.ScorableAsset{UpdatableAsset: viridian.UpdatableAsset{ReviewableAsset: viridian.ReviewableAsset{Status: viridian.Active}}}})
+			args := addProductArgs(productUUID, "7612100055557")
+			args[3] = `["` + producerKey + `", "` + otherProducerKey + `"]`
+			args[5] = `["productCategory-spreads"]`
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(status200), response.Message)
+
+			var product viridian.Product
+			Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+			Expect(product.Producers).To(Equal([]string{producerKey, otherProducerKey}))
+			Expect(product.ProductCategories).To(Equal([]string{"productCategory-spreads"}))
+
+			response = stub.Invoke("002", "queryProductsByProducer", otherProducerKey)
+			Expect(response.Status).Should(Equal(status200), response.Message)
+			Expect(keys(response.Payload)).To(Equal([]string{productKey}))
+		})
+
+		It("Should reject product categories that are not keys", func() {
+			args := addProductArgs(productUUID, "7612100055557")
+			args[5] = `["productCategory-spreads", "Brotaufstrich"]`
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring(`'productCategories[1]' "Brotaufstrich" is not a key of a product category`))
+		})
+
+		It("Should require the producers to be a list", func() {
+			args := addProductArgs(productUUID, "7612100055557")
+			args[3] = producerKey
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("'producers' must be a string with a JSON list"))
+		})
+
+		Describe("Products stored with a single producer, image URL and URL", func() {
+			BeforeEach(func() {
+				stub.PutFixture(productKey, json.RawMessage(fmt.Sprintf(`{"docType": "product", "gtin": "07612100055557",
+					"producer": "%s", "containedProducts": [], "labels": ["%s"], "status": %d,
+					"locales": [{"lang": "de", "name": "Ovomaltine", "imageUrl": "ipfs://QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o", "url": "https://www.ovomaltine.de/"}]}`,
+					producerKey, labelKey, viridian.Active)))
+			})
+
+			It("Should be read in the current shape", func() {
+				response := stub.Invoke("001", "readProduct", productKey)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				var doc map[string]interface{}
+				Expect(json.Unmarshal(response.Payload, &doc)).To(Succeed())
+				Expect(doc).NotTo(HaveKey("producer"))
+				Expect(doc["producers"]).To(Equal([]interface{}{producerKey}))
+
+				var product viridian.Product
+				Expect(json.Unmarshal(response.Payload, &product)).To(Succeed())
+				Expect(product.Locales[0].ImageURLs).To(Equal([]string{"ipfs://QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"}))
+				Expect(product.Locales[0].URLs).To(Equal([]string{"https://www.ovomaltine.de/"}))
+			})
+
+			It("Should be found by producer", func() {
+				args := addProductArgs(editUUID, "")
+				response := stub.Invoke("001", args...)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+
+				response = stub.Invoke("002", "queryProductsByProducer", producerKey)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				Expect(keys(response.Payload)).To(ConsistOf(productKey, editKey))
+			})
+
+			It("Should be returned in the current shape by queries and histories", func() {
+				expectCurrentShape := func(value json.RawMessage) {
+					var doc map[string]interface{}
+					Expect(json.Unmarshal(value, &doc)).To(Succeed())
+					Expect(doc).NotTo(HaveKey("producer"))
+					Expect(doc["producers"]).To(Equal([]interface{}{producerKey}))
+				}
+
+				response := stub.Invoke("001", "queryProductsByProducer", producerKey)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				var records []viridian.QueryRecord
+				Expect(json.Unmarshal(response.Payload, &records)).To(Succeed())
+				Expect(records).To(HaveLen(1))
+				expectCurrentShape(records[0].Value)
+
+				response = stub.Invoke("002", "queryProductsByProducerWithPagination", producerKey, "10", "")
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				var page viridian.QueryPage
+				Expect(json.Unmarshal(response.Payload, &page)).To(Succeed())
+				Expect(page.Records).To(HaveLen(1))
+				expectCurrentShape(page.Records[0].Value)
+
+				response = stub.Invoke("003", "queryAssets", "product",
+					`{"selector": {"producer": "`+producerKey+`"}, "use_index": ["indexDocTypeDoc", "indexDocType"]}`, "")
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				Expect(json.Unmarshal(response.Payload, &page)).To(Succeed())
+				Expect(page.Records).To(HaveLen(1))
+				expectCurrentShape(page.Records[0].Value)
+
+				response = stub.Invoke("004", "getHistoryForProduct", productKey)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+				var entries []viridian.HistoryEntry
+				Expect(json.Unmarshal(response.Payload, &entries)).To(Succeed())
+				Expect(entries).To(HaveLen(1))
+				expectCurrentShape(entries[0].Value)
+			})
+
+			It("Should be editable and stored in the current shape when superseded", func() {
+				response := stub.Invoke("001", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+				Expect(response.Status).Should(Equal(status200), response.Message)
+
+				var doc map[string]interface{}
+				Expect(json.Unmarshal(stub.State[productKey], &doc)).To(Succeed())
+				Expect(doc["supersededBy"]).To(Equal(editKey))
+				Expect(doc).NotTo(HaveKey("producer"))
+				Expect(doc["producers"]).To(Equal([]interface{}{producerKey}))
+			})
 		})
 	})
 })
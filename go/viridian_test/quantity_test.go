@@ -0,0 +1,101 @@
+package viridian_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+	"github.com/chaincode/viridian/go/viridian/quantity"
+)
+
+var _ = Describe("Quantity", func() {
+	DescribeTable("Parsing quantities",
+		func(s string, expected quantity.Quantity) {
+			q, err := quantity.Parse(s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(q).To(Equal(expected))
+		},
+		Entry("grams", "400 g", quantity.Quantity{Value: 400, Unit: "g", Count: 1}),
+		Entry("without space", "400g", quantity.Quantity{Value: 400, Unit: "g", Count: 1}),
+		Entry("kilograms with decimal comma", "1,5 kg", quantity.Quantity{Value: 1500, Unit: "g", Count: 1}),
+		Entry("milligrams", "500 mg", quantity.Quantity{Value: 0.5, Unit: "g", Count: 1}),
+		Entry("uppercase liters", "1.5 L", quantity.Quantity{Value: 1500, Unit: "ml", Count: 1}),
+		Entry("centiliters", "33 cl", quantity.Quantity{Value: 330, Unit: "ml", Count: 1}),
+		Entry("deciliters", "5 dl", quantity.Quantity{Value: 500, Unit: "ml", Count: 1}),
+		Entry("ounces", "16 oz", quantity.Quantity{Value: 453.592, Unit: "g", Count: 1}),
+		Entry("pounds", "1 lb", quantity.Quantity{Value: 453.592, Unit: "g", Count: 1}),
+		Entry("fluid ounces", "12 fl oz", quantity.Quantity{Value: 354.882, Unit: "ml", Count: 1}),
+		Entry("gallons", "1 gal", quantity.Quantity{Value: 3785.412, Unit: "ml", Count: 1}),
+		Entry("US pints", "1 US pt", quantity.Quantity{Value: 473.176, Unit: "ml", Count: 1}),
+		Entry("imperial pints", "1 imp pt", quantity.Quantity{Value: 568.261, Unit: "ml", Count: 1}),
+		Entry("imperial fluid ounces", "10 imp fl oz", quantity.Quantity{Value: 284.131, Unit: "ml", Count: 1}),
+		Entry("imperial gallons", "1 imp gal", quantity.Quantity{Value: 4546.09, Unit: "ml", Count: 1}),
+		Entry("pieces", "20 Stück", quantity.Quantity{Value: 20, Unit: "pcs", Count: 1}),
+		Entry("multipack", "6 x 330 ml", quantity.Quantity{Value: 330, Unit: "ml", Count: 6}),
+		Entry("multipack without spaces", "4x125g", quantity.Quantity{Value: 125, Unit: "g", Count: 4}),
+		Entry("multipack with multiplication sign", "6 × 0,5 l", quantity.Quantity{Value: 500, Unit: "ml", Count: 6}),
+		Entry("estimated sign", "400 g ℮", quantity.Quantity{Value: 400, Unit: "g", Count: 1}),
+		Entry("drained weight before", "Abtropfgewicht: 240 g", quantity.Quantity{Value: 240, Unit: "g", Count: 1, Drained: true}),
+		Entry("drained weight after", "240 g drained", quantity.Quantity{Value: 240, Unit: "g", Count: 1, Drained: true}),
+		Entry("French drained weight", "poids net égoutté 240 g", quantity.Quantity{Value: 240, Unit: "g", Count: 1, Drained: true}),
+	)
+
+	DescribeTable("Rejecting unparsable quantities",
+		func(s string, message string) {
+			_, err := quantity.Parse(s)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("empty", "", `must be a number with a unit, e.g. "400 g"`),
+		Entry("without unit", "400", "must be a number with a unit"),
+		Entry("without number", "g", "must be a number with a unit"),
+		Entry("words", "a big jar", "must be a number with a unit"),
+		Entry("unknown unit", "400 gramm", `unknown unit "gramm", expected one of cl, dl, fl oz`),
+		Entry("zero", "0 g", "greater than zero"),
+		Entry("empty multipack", "0 x 330 ml", "at least one item"),
+		Entry("too many items", "99999999999999999999 x 330 ml", "invalid number of items"),
+		Entry("drained volume", "drained 330 ml", "not a unit of mass"),
+		Entry("negative", "-400 g", "must be a number with a unit"),
+	)
+
+	It("Should compute the total of a multipack", func() {
+		q, err := quantity.Parse("6 x 330 ml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q.Total()).To(Equal(1980.0))
+	})
+
+	Describe("Quantities of products", func() {
+		var stub *testStub
+
+		BeforeEach(func() {
+			stub = newTestStub()
+			stub.Init("000", reviewConfig)
+			registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+			putReferencedAssets(stub)
+		})
+
+		It("Should store the normalized quantities alongside the raw ones", func() {
+			args := addProductArgs(productUUID, "")
+			args[7] = `[{"lang": "de", "name": "Ovomaltine", "quantities": ["400 g", "6 x 25 g"]}, {"lang": "fr", "name": "Ovomaltine"}]`
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(int32(200)), response.Message)
+
+			var product viridian.Product
+			Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+			Expect(product.Locales[0].Quantities).To(Equal([]string{"400 g", "6 x 25 g"}))
+			Expect(product.Locales[0].NormalizedQuantities).To(Equal([]quantity.Quantity{
+				{Value: 400, Unit: "g", Count: 1},
+				{Value: 25, Unit: "g", Count: 6},
+			}))
+			Expect(product.Locales[1].NormalizedQuantities).To(BeEmpty())
+		})
+
+		It("Should reject unparsable quantities with the path and the reason", func() {
+			args := addProductArgs(productUUID, "")
+			args[7] = `[{"lang": "de", "name": "Ovomaltine", "quantities": ["400 g", "a big jar"]}]`
+			response := stub.Invoke("001", args...)
+			Expect(response.Status).Should(Equal(int32(500)))
+			Expect(response.Message).To(ContainSubstring(`{"field":"locales[0].quantities[1]","value":"a big jar","problem":"MALFORMED","message":"quantity \"a big jar\" must be a number with a unit`))
+		})
+	})
+})
@@ -0,0 +1,143 @@
+package viridian_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+var _ = Describe("Ad hoc queries", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+	useIndex := `"use_index": ["indexDocTypeDoc", "indexDocType"]`
+
+	queryAssets := func(docType string, query string, bookmark string) viridian.QueryPage {
+		response := stub.Invoke("query", "queryAssets", docType, query, bookmark)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		var page viridian.QueryPage
+		Expect(json.Unmarshal(response.Payload, &page)).To(Succeed())
+		return page
+	}
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", `{"reviewersPerAsset": 5, "reviewQuorum": 3, "maxQueryLimit": 2}`)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	It("Should only return assets of the queried docType", func() {
+		page := queryAssets("product", `{"selector": {"status": 1}, `+useIndex+`}`, "")
+		Expect(page.Records).To(HaveLen(1))
+		Expect(page.Records[0].Key).To(Equal(productKey))
+
+		page = queryAssets("label", `{"selector": {"status": 2}, `+useIndex+`}`, "")
+		Expect(page.Records).To(HaveLen(1))
+		Expect(page.Records[0].Key).To(Equal(labelKey))
+
+		var query map[string]interface{}
+		Expect(json.Unmarshal([]byte(stub.Queries[len(stub.Queries)-1]), &query)).To(Succeed())
+		Expect(query["selector"]).To(HaveKeyWithValue("docType", "label"))
+		Expect(query["use_index"]).To(Equal([]interface{}{"indexDocTypeDoc", "indexDocType"}))
+	})
+
+	It("Should sort by a field of the index", func() {
+		page := queryAssets("product", `{"selector": {"status": {"$gt": 0}}, "sort": [{"status": "desc"}], "use_index": ["indexStatusDoc", "indexStatus"]}`, "")
+		Expect(page.Records).To(HaveLen(1))
+		Expect(page.Records[0].Key).To(Equal(productKey))
+	})
+
+	It("Should match fields of array elements", func() {
+		page := queryAssets("product", `{"selector": {"locales": {"$elemMatch": {"lang": "de"}}}, `+useIndex+`}`, "")
+		Expect(page.Records).To(HaveLen(1))
+		page = queryAssets("product", `{"selector": {"locales": {"$elemMatch": {"lang": "fr"}}}, `+useIndex+`}`, "")
+		Expect(page.Records).To(BeEmpty())
+	})
+
+	It("Should cap the limit and page through the results", func() {
+		for i := 0; i < 3; i++ {
+			response := stub.Invoke(fmt.Sprintf("label-%d", i), "addLabel", fmt.Sprintf("%d5b1c3f2-0000-4000-8000-000000000000", i),
+				`[{"lang": "de", "name": "Bio"}]`, "")
+			Expect(response.Status).Should(Equal(status200), response.Message)
+		}
+		query := `{"selector": {"version": ""}, "limit": 10, ` + useIndex + `}`
+		first := queryAssets("label", query, "")
+		Expect(first.Records).To(HaveLen(2))
+		second := queryAssets("label", query, first.Bookmark)
+		Expect(second.Records).To(HaveLen(2))
+		Expect(second.Records[0].Key).NotTo(Equal(first.Records[1].Key))
+
+		Expect(queryAssets("label", `{"selector": {"version": ""}, "limit": 1, `+useIndex+`}`, "").Records).To(HaveLen(1))
+	})
+
+	It("Should accept every index shipped in META-INF for a selector on its fields", func() {
+		indexes, err := shippedIndexes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(indexes).NotTo(BeEmpty())
+		for _, index := range indexes {
+			selector := map[string]interface{}{}
+			for _, field := range index.Index.Fields[1:] { // the first field is the docType
+				selector[field] = "x"
+			}
+			if len(selector) == 0 {
+				selector["status"] = 1
+			}
+			query, err := json.Marshal(map[string]interface{}{"selector": selector, "use_index": []string{index.Ddoc, index.Name}})
+			Expect(err).NotTo(HaveOccurred())
+			accepted := false
+			for _, docType := range []string{"product", "producer", "label", "review", "information", "comment"} {
+				response := stub.Invoke("query", "queryAssets", docType, string(query))
+				accepted = accepted || response.Status == status200
+			}
+			Expect(accepted).To(BeTrue(), index.Name)
+		}
+	})
+
+	DescribeTable("Should reject queries outside the allowlist",
+		func(docType string, query string, message string) {
+			response := stub.Invoke("002", "queryAssets", docType, query)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring(message))
+			Expect(stub.Queries).To(BeEmpty())
+		},
+		Entry("an unknown docType", "user", `{"selector": {"status": 1}, `+useIndex+`}`, "cannot be queried"),
+		Entry("invalid JSON", "product", `{"selector": `, "'query' must be"),
+		Entry("an unknown query parameter", "product", `{"selector": {"status": 1}, "fields": ["gtin"], `+useIndex+`}`, "'query' must be"),
+		Entry("an empty selector", "product", `{"selector": {}, `+useIndex+`}`, "must not be empty"),
+		Entry("a docType condition", "product", `{"selector": {"docType": {"$gt": null}}, `+useIndex+`}`, "'docType'"),
+		Entry("a field of another docType", "producer", `{"selector": {"gtin": "07612100055557"}, `+useIndex+`}`, "Field 'gtin'"),
+		Entry("a field not on the allowlist", "product", `{"selector": {"score.climate": 10}, `+useIndex+`}`, "Field 'score.climate'"),
+		Entry("a field of array elements outside $elemMatch", "product", `{"selector": {"locales.lang": "de"}, `+useIndex+`}`, "Field 'locales.lang'"),
+		Entry("an unknown element field", "product", `{"selector": {"locales": {"$elemMatch": {"price": "1"}}}, `+useIndex+`}`, "Field 'locales.price'"),
+		Entry("$regex", "product", `{"selector": {"gtin": {"$regex": ".*"}}, `+useIndex+`}`, "'$regex'"),
+		Entry("$where", "product", `{"selector": {"$where": "true"}, `+useIndex+`}`, "'$where'"),
+		Entry("$nor", "product", `{"selector": {"$nor": [{"status": 1}]}, `+useIndex+`}`, "'$nor'"),
+		Entry("an object value", "product", `{"selector": {"gtin": {"$eq": {"$gt": null}}}, `+useIndex+`}`, "'$eq' on 'gtin'"),
+		Entry("an operator in $in", "product", `{"selector": {"gtin": {"$in": [{"$gt": null}]}}, `+useIndex+`}`, "'$in' on 'gtin'"),
+		Entry("too many values in $in", "product", `{"selector": {"gtin": {"$in": [`+strings.Repeat(`"1", `, 50)+`"1"]}}, `+useIndex+`}`, "at most 50"),
+		Entry("too deeply nested selectors", "product",
+			`{"selector": {"$or": [{"$or": [{"$or": [{"$or": [{"$or": [{"status": 1}]}]}]}]}]}, `+useIndex+`}`, "levels deep"),
+		Entry("too many conditions", "product",
+			`{"selector": {"$or": [`+strings.Repeat(`{"status": 1}, `, 20)+`{"status": 2}]}, `+useIndex+`}`, "conditions"),
+		Entry("sorting by a field not on the allowlist", "product",
+			`{"selector": {"status": 1}, "sort": [{"score.climate": "asc"}], `+useIndex+`}`, "Cannot sort"),
+		Entry("sorting by a field not in the index", "product",
+			`{"selector": {"gtin": "07612100055557"}, "sort": [{"gtin": "asc"}], `+useIndex+`}`, "Cannot sort by 'gtin'"),
+		Entry("sorting in an unknown direction", "product",
+			`{"selector": {"status": 1}, "sort": [{"status": "up"}], "use_index": ["indexStatusDoc", "indexStatus"]}`, "Cannot sort"),
+		Entry("a negative limit", "product", `{"selector": {"status": 1}, "limit": -1, `+useIndex+`}`, "'limit'"),
+		Entry("no index", "product", `{"selector": {"status": 1}}`, "'use_index'"),
+		Entry("only the design document of an index", "product", `{"selector": {"status": 1}, "use_index": ["indexDocTypeDoc"]}`, "'use_index'"),
+		Entry("an index on a field without condition", "product", `{"selector": {"status": 1}, "use_index": ["indexProductGTINDoc", "indexProductGTIN"]}`, "'gtin'"),
+		Entry("an index not shipped with the chaincode", "product", `{"selector": {"status": 1}, "use_index": ["_design/all", "all"]}`, "'use_index'"),
+	)
+})
@@ -0,0 +1,61 @@
+package viridian_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reading assets", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	DescribeTable("Should return the stored JSON",
+		func(function string, key string) {
+			response := stub.Invoke("002", function, key)
+			Expect(response.Status).Should(Equal(status200), response.Message)
+			Expect(response.Payload).To(Equal(stub.State[key]))
+		},
+		Entry("readProduct", "readProduct", productKey),
+		Entry("readProducer", "readProducer", producerKey),
+		Entry("readLabel", "readLabel", labelKey),
+	)
+
+	DescribeTable("Should reject",
+		func(function string, key string, message string) {
+			response := stub.Invoke("002", function, key)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(Equal(message))
+		},
+		Entry("a product that does not exist", "readProduct", "product-does-not-exist", "Product product-does-not-exist does not exist"),
+		Entry("an empty key", "readProducer", "", "Producer key not provided"),
+		Entry("a producer read as product", "readProduct", producerKey, producerKey+" is not a product"),
+		Entry("a product read as label", "readLabel", productKey, productKey+" is not a label"),
+	)
+
+	It("Should reject index entries, which are not JSON", func() {
+		for key := range stub.State {
+			if key[0] == 0x00 { // composite key
+				response := stub.Invoke("002", "readProduct", key)
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("is not a product"))
+			}
+		}
+	})
+
+	It("Should require exactly one argument", func() {
+		response := stub.Invoke("002", "readProduct", productKey, producerKey)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("Expecting 1"))
+	})
+})
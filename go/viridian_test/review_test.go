@@ -0,0 +1,355 @@
+package viridian_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+// openReviews returns the keys of the pending reviews of target by reviewer name
+func openReviews(stub *testStub, target string) map[string]string {
+	names := make(map[string]string)
+	for key := range stub.State {
+		var user viridian.User
+		if strings.HasPrefix(key, "user-") && stub.GetFixture(key, &user) {
+			names[user.ID] = user.Name
+		}
+	}
+	reviews := make(map[string]string)
+	for key := range stub.State {
+		var review viridian.Review
+		if strings.HasPrefix(key, "review-") && stub.GetFixture(key, &review) &&
+			review.Target == target && review.Decision == viridian.Pending {
+			reviews[names[review.User]] = key
+		}
+	}
+	return reviews
+}
+
+// anyReview returns the name of one of the appointed reviewers of target
+// and the key of their review
+func anyReview(stub *testStub, target string) (string, string) {
+	reviews := openReviews(stub, target)
+	var names []string
+	for name := range reviews {
+		names = append(names, name)
+	}
+	Expect(names).NotTo(BeEmpty())
+	sort.Strings(names)
+	return names[0], reviews[names[0]]
+}
+
+// decide lets n of the appointed reviewers of target submit the decision
+func decide(stub *testStub, target string, decision string, n int) {
+	reviews := openReviews(stub, target)
+	var names []string
+	for name := range reviews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	Expect(len(names)).To(BeNumerically(">=", n))
+
+	rejectReason := ""
+	if decision == "REJECTED" {
+		rejectReason = "INCORRECT"
+	}
+	for i, name := range names[:n] {
+		stub.SetCreator(name)
+		response := stub.Invoke(fmt.Sprintf("decide-%s-%d", target, i), "submitReview", reviews[name], decision, rejectReason, "")
+		Expect(response.Status).Should(Equal(int32(200)), response.Message)
+	}
+}
+
+func productStatus(stub *testStub, key string) viridian.Status {
+	var product viridian.Product
+	Expect(stub.GetFixture(key, &product)).To(BeTrue())
+	return product.Status
+}
+
+var _ = Describe("Review", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status200))
+	})
+
+	Describe("Reviewing a new product", func() {
+		It("Should appoint reviewers other than the creator", func() {
+			reviews := openReviews(stub, productKey)
+			Expect(reviews).To(HaveLen(3))
+			Expect(reviews).NotTo(HaveKey("user1"))
+		})
+
+		It("Should activate the product once the quorum approved", func() {
+			decide(stub, productKey, "APPROVED", 1)
+			Expect(productStatus(stub, productKey)).To(Equal(viridian.Preliminary))
+			decide(stub, productKey, "APPROVED", 1)
+			Expect(productStatus(stub, productKey)).To(Equal(viridian.Active))
+			Expect(openReviews(stub, productKey)).To(BeEmpty())
+		})
+
+		It("Should reject the product once the quorum cannot be reached anymore", func() {
+			decide(stub, productKey, "APPROVED", 1)
+			decide(stub, productKey, "REJECTED", 1)
+			Expect(productStatus(stub, productKey)).To(Equal(viridian.Preliminary))
+			decide(stub, productKey, "REJECTED", 1)
+			Expect(productStatus(stub, productKey)).To(Equal(viridian.Rejected))
+		})
+
+		It("Should not accept a decision from a user who was not appointed", func() {
+			reviews := openReviews(stub, productKey)
+			for _, reviewKey := range reviews {
+				stub.SetCreator("user1")
+				response := stub.Invoke("002", "submitReview", reviewKey, "APPROVED", "", "")
+				Expect(response.Status).Should(Equal(status500))
+				Expect(response.Message).To(ContainSubstring("another user"))
+			}
+		})
+
+		It("Should not accept two decisions on the same review", func() {
+			reviewer, reviewKey := anyReview(stub, productKey)
+			stub.SetCreator(reviewer)
+			response := stub.Invoke("002", "submitReview", reviewKey, "APPROVED", "", "")
+			Expect(response.Status).Should(Equal(status200))
+			response = stub.Invoke("003", "submitReview", reviewKey, "REJECTED", "INCORRECT", "")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("already been closed"))
+		})
+
+		It("Should require a reject reason when rejecting", func() {
+			reviewer, reviewKey := anyReview(stub, productKey)
+			stub.SetCreator(reviewer)
+			response := stub.Invoke("002", "submitReview", reviewKey, "REJECTED", "", "I don't like it.")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("rejectReason"))
+		})
+
+		It("Should reject unknown decisions", func() {
+			reviewer, reviewKey := anyReview(stub, productKey)
+			stub.SetCreator(reviewer)
+			response := stub.Invoke("002", "submitReview", reviewKey, "PENDING", "", "")
+			Expect(response.Status).Should(Equal(status500))
+		})
+	})
+
+	Describe("Reviewing an edit", func() {
+		BeforeEach(func() {
+			decide(stub, productKey, "APPROVED", 2)
+			stub.SetCreator("user2")
+			response := stub.Invoke("002", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+		})
+
+		It("Should exclude the creator and the editor from the review", func() {
+			reviews := openReviews(stub, editKey)
+			Expect(reviews).To(HaveLen(3))
+			Expect(reviews).NotTo(HaveKey("user1"))
+			Expect(reviews).NotTo(HaveKey("user2"))
+		})
+
+		It("Should outdate the old version when approved", func() {
+			decide(stub, editKey, "APPROVED", 2)
+			Expect(productStatus(stub, editKey)).To(Equal(viridian.Active))
+			Expect(productStatus(stub, productKey)).To(Equal(viridian.Outdated))
+		})
+
+		It("Should keep the old version when rejected", func() {
+			decide(stub, editKey, "REJECTED", 2)
+			Expect(productStatus(stub, editKey)).To(Equal(viridian.Rejected))
+
+			var oldProduct viridian.Product
+			Expect(stub.GetFixture(productKey, &oldProduct)).To(BeTrue())
+			Expect(oldProduct.Status).To(Equal(viridian.Active))
+			Expect(oldProduct.SupersededBy).To(BeEmpty())
+		})
+	})
+
+	Describe("Reviewing a deletion", func() {
+		var activeProduct viridian.Product
+
+		BeforeEach(func() {
+			decide(stub, productKey, "APPROVED", 2)
+			Expect(stub.GetFixture(productKey, &activeProduct)).To(BeTrue())
+			stub.SetCreator("user2")
+			response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+			Expect(response.Status).Should(Equal(status200))
+		})
+
+		It("Should keep the deletion request in the reviews", func() {
+			_, reviewKey := anyReview(stub, productKey)
+			var review viridian.Review
+			Expect(stub.GetFixture(reviewKey, &review)).To(BeTrue())
+			Expect(review.RequestedBy).NotTo(Equal(activeProduct.CreatedBy))
+			Expect(review.ChangeReason).To(Equal("This product does not exist."))
+		})
+
+		It("Should delete the product with the reason of the request when approved", func() {
+			_, reviewKey := anyReview(stub, productKey)
+			var review viridian.Review
+			Expect(stub.GetFixture(reviewKey, &review)).To(BeTrue())
+			decide(stub, productKey, "APPROVED", 2)
+
+			var product viridian.Product
+			Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+			Expect(product.Status).To(Equal(viridian.Deleted))
+			Expect(product.UpdatedBy).To(Equal(review.RequestedBy))
+			Expect(product.UpdatedAt).To(Equal(review.RequestedAt))
+			Expect(product.ChangeReason).To(Equal("This product does not exist."))
+		})
+
+		It("Should clear the deletion request and keep the last update when rejected", func() {
+			decide(stub, productKey, "REJECTED", 2)
+
+			var product viridian.Product
+			Expect(stub.GetFixture(productKey, &product)).To(BeTrue())
+			Expect(product.Status).To(Equal(viridian.Active))
+			Expect(product.SupersededBy).To(BeEmpty())
+			Expect(product.UpdatedBy).To(Equal(activeProduct.UpdatedBy))
+			Expect(product.UpdatedAt).To(Equal(activeProduct.UpdatedAt))
+			Expect(product.ChangeReason).To(Equal(activeProduct.ChangeReason))
+		})
+	})
+
+	It("Should appoint reviewers for a new producer", func() {
+		response := stub.Invoke("002", "initProducer", wanderUUID, wanderLocales, "[]")
+		Expect(response.Status).Should(Equal(status200))
+		Expect(openReviews(stub, wanderKey)).To(HaveLen(3))
+	})
+
+	It("Should fail if there are not enough users to review", func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2")
+		putReferencedAssets(stub)
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("Not enough registered users"))
+	})
+
+	It("Should appoint a user to review a limited number of assets of the same submitter", func() {
+		stub = newTestStub()
+		stub.Init("000", `{"reviewersPerAsset": 2, "reviewQuorum": 2, "maxReviewsPerSubmitter": 1}`)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		stub.SetCreator("user1")
+		response := stub.Invoke("001", addProductArgs(productUUID, "")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		response = stub.Invoke("002", addProductArgs(editUUID, "")...)
+		Expect(response.Status).Should(Equal(status200), response.Message)
+		for name := range openReviews(stub, productKey) {
+			Expect(openReviews(stub, editKey)).NotTo(HaveKey(name))
+		}
+
+		response = stub.Invoke("003", addProductArgs("0b7e1f52-6c1d-4f8a-9e2b-3d4c5a6b7c8d", "")...)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("Not enough registered users"))
+
+		stub.SetCreator("user2")
+		response = stub.Invoke("004", "initProducer", wanderUUID, wanderLocales, "[]")
+		Expect(response.Status).Should(Equal(status200), response.Message)
+	})
+
+	Describe("Selecting reviewers", func() {
+		users := []string{"user1", "user2", "user3", "user4", "user5", "user6", "user7", "user8", "user9", "user10"}
+
+		// selection adds a product in transaction txID on a fresh stub and
+		// returns the names of the appointed reviewers
+		selection := func(txID string) []string {
+			s := newTestStub()
+			s.TxTimestamp = &timestamp.Timestamp{Seconds: 1555555555, Nanos: 42}
+			s.Init("000", reviewConfig)
+			registerUsers(s, users...)
+			putReferencedAssets(s)
+			response := s.Invoke(txID, addProductArgs(productUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+			var names []string
+			for name := range openReviews(s, productKey) {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return names
+		}
+
+		It("Should select the same reviewers on every peer", func() {
+			Expect(selection("a1f2")).To(Equal(selection("a1f2")))
+		})
+
+		It("Should select different reviewers for different transactions", func() {
+			first := selection("tx-0")
+			different := false
+			for i := 1; i < 10 && !different; i++ {
+				different = fmt.Sprint(selection(fmt.Sprintf("tx-%d", i))) != fmt.Sprint(first)
+			}
+			Expect(different).To(BeTrue())
+		})
+
+		It("Should not select users with an open conflict", func() {
+			decide(stub, productKey, "APPROVED", 2)
+			for i, name := range []string{"user3", "user4"} {
+				stub.SetCreator(name)
+				response := stub.Invoke(fmt.Sprintf("conflict-%d", i), "declareConflict", productKey, "I work for the producer.")
+				Expect(response.Status).Should(Equal(status200))
+			}
+			stub.SetCreator("user2")
+			response := stub.Invoke("002", "deleteProduct", productKey, "This product does not exist.")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("Not enough registered users"))
+		})
+
+		It("Should not select users with an open conflict with the producer", func() {
+			for i, name := range []string{"user2", "user3", "user4"} {
+				stub.SetCreator(name)
+				response := stub.Invoke(fmt.Sprintf("conflict-%d", i), "declareConflict", producerKey, "I work for this company.")
+				Expect(response.Status).Should(Equal(status200), response.Message)
+			}
+			stub.SetCreator("user1")
+			response := stub.Invoke("002", addProductArgs(editUUID, "")...)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("Not enough registered users"))
+		})
+
+		It("Should require the reason of a conflict", func() {
+			stub.SetCreator("user3")
+			response := stub.Invoke("002", "declareConflict", productKey, "")
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("Reason not provided"))
+			response = stub.Invoke("003", "withdrawConflict", productKey)
+			Expect(response.Status).Should(Equal(status500))
+			Expect(response.Message).To(ContainSubstring("No conflict"))
+		})
+
+		It("Should not select users with an open conflict on the superseded version", func() {
+			decide(stub, productKey, "APPROVED", 2)
+			for i, name := range []string{"user3", "user4"} {
+				stub.SetCreator(name)
+				response := stub.Invoke(fmt.Sprintf("conflict-%d", i), "declareConflict", productKey, "I work for the producer.")
+				Expect(response.Status).Should(Equal(status200))
+			}
+			stub.SetCreator("user3")
+			response := stub.Invoke("002", "withdrawConflict", productKey)
+			Expect(response.Status).Should(Equal(status200))
+
+			stub.SetCreator("user2")
+			response = stub.Invoke("003", editProductArgs(productKey, "Wrong quantity information.", editUUID, "7612100055557")...)
+			Expect(response.Status).Should(Equal(status200))
+			reviews := openReviews(stub, editKey)
+			Expect(reviews).To(HaveLen(2))
+			Expect(reviews).To(HaveKey("user3"))
+			Expect(reviews).To(HaveKey("user5"))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package viridian_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Selectors", func() {
+	var stub *testStub
+
+	// lastSelector returns the selector of the last rich query, which must be valid JSON
+	lastSelector := func() map[string]interface{} {
+		Expect(stub.Queries).NotTo(BeEmpty())
+		var q struct {
+			Selector map[string]interface{} `json:"selector"`
+		}
+		Expect(json.Unmarshal([]byte(stub.Queries[len(stub.Queries)-1]), &q)).To(Succeed())
+		return q.Selector
+	}
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+		registerUsers(stub, "user1", "user2", "user3", "user4", "user5")
+		putReferencedAssets(stub)
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(int32(200)), response.Message)
+		response = stub.Invoke("002", "addLabel", "5b8c1e8e-2f57-4f0f-9a4e-0d7c8f3a1b01", `[{"lang": "de", "name": "Knospe"}]`, "")
+		Expect(response.Status).Should(Equal(int32(200)), response.Message)
+	})
+
+	hostileInputs := []TableEntry{
+		Entry("a quote", `Ovomaltine "crunchy" cream`),
+		Entry("a backslash", `Ovomaltine \`),
+		Entry("an injected condition", `x", "docType": {"$gt": null}, "name": "`),
+		Entry("an injected $or", `x"}], "$or": [{"docType": "product"}, {"docType": "label"}], "y": [{"name": "`),
+		Entry("an operator object", `{"$regex": ".*"}`),
+		Entry("control characters", "Ovo\n\t\u0000maltine"),
+	}
+
+	DescribeTable("Should keep hostile product names inside a JSON string",
+		func(name string) {
+			response := stub.Invoke("003", "queryProductsByName", name, name)
+			Expect(response.Status).Should(Equal(int32(200)), response.Message)
+			Expect(string(response.Payload)).To(Equal("[]"))
+
+			selector := lastSelector()
+			Expect(selector).To(HaveLen(2))
+			Expect(selector["docType"]).To(Equal("product"))
+			Expect(selector["locales"]).To(Equal(map[string]interface{}{
+				"$elemMatch": map[string]interface{}{"lang": name, "name": name}}))
+		},
+		hostileInputs...,
+	)
+
+	DescribeTable("Should keep hostile label names inside a JSON string",
+		func(name string) {
+			response := stub.Invoke("003", "queryLabelsByNameWithPagination", name, name, "", "10", "")
+			Expect(response.Status).Should(Equal(int32(200)), response.Message)
+			Expect(string(response.Payload)).To(ContainSubstring(`"records":[]`))
+
+			selector := lastSelector()
+			Expect(selector).To(HaveLen(2))
+			Expect(selector["docType"]).To(Equal("label"))
+			Expect(selector["locales"]).To(Equal(map[string]interface{}{
+				"$elemMatch": map[string]interface{}{"lang": name, "name": name}}))
+		},
+		hostileInputs...,
+	)
+
+	DescribeTable("Should quote regular expression syntax in name searches",
+		func(name string) {
+			response := stub.Invoke("003", "queryProductsByName", name, "", "SUBSTRING")
+			Expect(response.Status).Should(Equal(int32(200)), response.Message)
+			Expect(string(response.Payload)).To(Equal("[]"))
+		},
+		Entry("a wildcard", ".*"),
+		Entry("an alternation", "x|Ovo"),
+		Entry("a character class", "[a-z]"),
+		Entry("a group ending the pattern early", ")|(.*"),
+	)
+
+	It("Should reject hostile GTINs before querying", func() {
+		response := stub.Invoke("003", "queryProductsByGTIN", `07612100055557", "$or": [{}], "x": "`)
+		Expect(response.Status).Should(Equal(int32(500)))
+		Expect(stub.Queries).To(BeEmpty())
+	})
+})
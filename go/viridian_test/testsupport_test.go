@@ -0,0 +1,177 @@
+package viridian_test
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/chaincode/viridian/go/testsupport"
+	"github.com/chaincode/viridian/go/viridian"
+)
+
+var _ = Describe("Test support", func() {
+	var doc map[string]interface{}
+	BeforeEach(func() {
+		Expect(json.Unmarshal([]byte(`{
+			"docType": "product",
+			"gtin": "07612100055557",
+			"status": 2,
+			"labels": ["label-1", "label-2"],
+			"score": {"climate": -10},
+			"locales": [
+				{"lang": "de", "name": "Ovomaltine", "categories": ["Brotaufstrich"]},
+				{"lang": "fr", "name": "Ovomaltine crunchy"}
+			]
+		}`), &doc)).To(Succeed())
+	})
+
+	DescribeTable("Should evaluate Mango selectors",
+		func(selector string, expected bool) {
+			var s map[string]interface{}
+			Expect(json.Unmarshal([]byte(selector), &s)).To(Succeed())
+			matches, err := testsupport.Matches(doc, s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(Equal(expected))
+		},
+		Entry("equality", `{"docType": "product", "status": 2}`, true),
+		Entry("unequal value", `{"docType": "label"}`, false),
+		Entry("missing field", `{"name": "Ovomaltine"}`, false),
+		Entry("$eq", `{"gtin": {"$eq": "07612100055557"}}`, true),
+		Entry("$ne", `{"status": {"$ne": 2}}`, false),
+		Entry("$ne on a missing field", `{"name": {"$ne": "x"}}`, false),
+		Entry("$gt and $lt", `{"status": {"$gt": 1, "$lt": 3}}`, true),
+		Entry("$gte", `{"status": {"$gte": 3}}`, false),
+		Entry("$lte on a string", `{"gtin": {"$lte": "1"}}`, true),
+		Entry("$gt null matches every value", `{"gtin": {"$gt": null}}`, true),
+		Entry("numbers sort before strings", `{"status": {"$lt": ""}}`, true),
+		Entry("$in", `{"status": {"$in": [1, 2]}}`, true),
+		Entry("$in on an array field", `{"labels": {"$in": ["label-2", "label-3"]}}`, true),
+		Entry("$nin", `{"status": {"$nin": [1, 2]}}`, false),
+		Entry("$exists", `{"gtin": {"$exists": true}, "name": {"$exists": false}}`, true),
+		Entry("$regex", `{"gtin": {"$regex": "^0761"}}`, true),
+		Entry("$regex on a number", `{"status": {"$regex": "2"}}`, false),
+		Entry("dotted field", `{"score.climate": -10}`, true),
+		Entry("$elemMatch on values", `{"labels": {"$elemMatch": {"$eq": "label-1"}}}`, true),
+		Entry("$elemMatch on objects", `{"locales": {"$elemMatch": {"lang": "fr", "name": {"$regex": "(?i)crunchy"}}}}`, true),
+		Entry("$elemMatch needs one element matching all", `{"locales": {"$elemMatch": {"lang": "de", "name": "Ovomaltine crunchy"}}}`, false),
+		Entry("nested $elemMatch", `{"locales": {"$elemMatch": {"categories": {"$elemMatch": {"$eq": "Brotaufstrich"}}}}}`, true),
+		Entry("$allMatch", `{"labels": {"$allMatch": {"$regex": "^label-"}}}`, true),
+		Entry("$size", `{"labels": {"$size": 2}}`, true),
+		Entry("$and", `{"$and": [{"status": 2}, {"gtin": "x"}]}`, false),
+		Entry("$or", `{"$or": [{"status": 1}, {"gtin": "07612100055557"}]}`, true),
+		Entry("$nor", `{"$nor": [{"status": 1}, {"gtin": "x"}]}`, true),
+		Entry("$not", `{"$not": {"status": 2}}`, false),
+		Entry("$not on a field", `{"status": {"$not": {"$eq": 1}}}`, true),
+		Entry("$not on a missing field", `{"name": {"$not": {"$eq": "x"}}}`, false),
+		Entry("equality with an array", `{"labels": ["label-1", "label-2"]}`, true),
+	)
+
+	It("Should reject unsupported operators", func() {
+		_, err := testsupport.Matches(doc, map[string]interface{}{"$where": "true"})
+		Expect(err).To(HaveOccurred())
+		_, err = testsupport.Matches(doc, map[string]interface{}{"gtin": map[string]interface{}{"$mod": []interface{}{2.0, 0.0}}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("Running queries", func() {
+		var stub *testsupport.Stub
+
+		keys := func(iterator shim.StateQueryIteratorInterface) []string {
+			keys := []string{}
+			for iterator.HasNext() {
+				kv, err := iterator.Next()
+				Expect(err).NotTo(HaveOccurred())
+				keys = append(keys, kv.Key)
+			}
+			return keys
+		}
+
+		BeforeEach(func() {
+			stub = testsupport.NewStub(new(viridian.Chaincode))
+			for i, name := range []string{"c", "a", "b", "a"} {
+				stub.PutFixture("producer-"+string(rune('1'+i)), map[string]interface{}{"docType": "producer", "name": name, "rank": i})
+			}
+			stub.PutFixture("label-1", map[string]interface{}{"docType": "label"})
+		})
+
+		It("Should sort, skip and limit the results", func() {
+			iterator, err := stub.GetQueryResult(`{"selector": {"docType": "producer"}, "sort": [{"name": "desc"}, "rank"]}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys(iterator)).To(Equal([]string{"producer-1", "producer-3", "producer-2", "producer-4"}))
+
+			iterator, err = stub.GetQueryResult(`{"selector": {"docType": "producer"}, "sort": ["name"], "skip": 1, "limit": 2}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys(iterator)).To(Equal([]string{"producer-4", "producer-3"}))
+			Expect(stub.Queries).To(HaveLen(2))
+		})
+
+		It("Should page through the results with bookmarks", func() {
+			query := `{"selector": {"docType": "producer"}, "sort": ["name"]}`
+			var pages [][]string
+			bookmark := ""
+			for len(pages) < 3 {
+				iterator, metadata, err := stub.GetQueryResultWithPagination(query, 3, bookmark)
+				Expect(err).NotTo(HaveOccurred())
+				page := keys(iterator)
+				Expect(metadata.FetchedRecordsCount).To(BeNumerically("==", len(page)))
+				pages = append(pages, page)
+				bookmark = metadata.Bookmark
+			}
+			Expect(pages).To(Equal([][]string{{"producer-2", "producer-4", "producer-3"}, {"producer-1"}, {}}))
+		})
+
+		It("Should check the index of queries and fail like LevelDB", func() {
+			stub.IndexDir = indexDir
+			_, err := stub.GetQueryResult(`{"selector": {"docType": "producer"}}`)
+			Expect(err).To(MatchError(ContainSubstring("use_index")))
+			_, err = stub.GetQueryResult(`{"selector": {"docType": "producer"}, "use_index": ["indexNameDoc", "indexName"]}`)
+			Expect(err).To(MatchError(ContainSubstring("condition on name")))
+			_, err = stub.GetQueryResult(`{"selector": {"docType": "producer", "name": "a"}, "sort": ["rank"], "use_index": "_design/indexNameDoc"}`)
+			Expect(err).To(MatchError(ContainSubstring("sort by rank")))
+			_, err = stub.GetQueryResult(`{"selector": {"docType": "producer", "name": "a"}, "use_index": ["indexNameDoc", "indexName"]}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			stub.LevelDB = true
+			_, err = stub.GetQueryResult(`{"selector": {"docType": "producer", "name": "a"}, "use_index": ["indexNameDoc", "indexName"]}`)
+			Expect(err).To(MatchError(ContainSubstring("leveldb")))
+		})
+	})
+
+	It("Should give every transaction a deterministic timestamp", func() {
+		stub := testsupport.NewStub(new(viridian.Chaincode))
+		stub.Init("000")
+		first, err := stub.GetTxTimestamp()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first.Seconds).To(Equal(testsupport.FirstTxTimestamp.Seconds))
+		stub.Invoke("001", "readProduct", productKey)
+		second, err := stub.GetTxTimestamp()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(second.Seconds).To(Equal(first.Seconds + 1))
+	})
+
+	It("Should let tests set the identity of the creator", func() {
+		stub := testsupport.NewStub(new(viridian.Chaincode))
+		stub.SetCreatorWithAttributes("Org2MSP", "alice", map[string]string{"role": "reviewer"})
+		mspID, err := cid.GetMSPID(stub)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mspID).To(Equal("Org2MSP"))
+		role, found, err := cid.GetAttributeValue(stub, "role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(role).To(Equal("reviewer"))
+		id, err := cid.GetID(stub)
+		Expect(err).NotTo(HaveOccurred())
+
+		stub.SetCreator("alice")
+		sameID, err := cid.GetID(stub)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sameID).To(Equal(id))
+		_, found, err = cid.GetAttributeValue(stub, "role")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+})
@@ -0,0 +1,69 @@
+package viridian_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// reviewConfig makes three users review each asset, two of which must approve
+const reviewConfig = `{"reviewersPerAsset": 3, "reviewQuorum": 2}`
+
+// registerUsers registers a user for each name and leaves the creator set
+// to the first one
+func registerUsers(stub *testStub, names ...string) {
+	for i, name := range names {
+		stub.SetCreator(name)
+		response := stub.Invoke(fmt.Sprintf("register-%d", i), "registerUser", name)
+		Expect(response.Status).Should(Equal(int32(200)), response.Message)
+	}
+	stub.SetCreator(names[0])
+}
+
+var _ = Describe("User", func() {
+	var stub *testStub
+	status200 := int32(200)
+	status500 := int32(500)
+
+	BeforeEach(func() {
+		stub = newTestStub()
+		stub.Init("000", reviewConfig)
+	})
+
+	It("Should be possible to register a user", func() {
+		stub.SetCreator("alice")
+		response := stub.Invoke("001", "registerUser", "alice")
+		Expect(response.Status).Should(Equal(status200))
+	})
+
+	It("Should not be possible to register the same identity twice", func() {
+		stub.SetCreator("alice")
+		stub.Invoke("001", "registerUser", "alice")
+		response := stub.Invoke("002", "registerUser", "alice2")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("already registered"))
+	})
+
+	It("Should not be possible to take another user's name", func() {
+		stub.SetCreator("alice")
+		stub.Invoke("001", "registerUser", "alice")
+		stub.SetCreator("bob")
+		response := stub.Invoke("002", "registerUser", "alice")
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("already taken"))
+	})
+
+	It("Should reject invalid user names", func() {
+		stub.SetCreator("alice")
+		response := stub.Invoke("001", "registerUser", "alice smith")
+		Expect(response.Status).Should(Equal(status500))
+	})
+
+	It("Should not be possible for unregistered users to add products", func() {
+		stub.SetCreator("mallory")
+		response := stub.Invoke("001", addProductArgs(productUUID, "7612100055557")...)
+		Expect(response.Status).Should(Equal(status500))
+		Expect(response.Message).To(ContainSubstring("not registered"))
+	})
+})